@@ -0,0 +1,53 @@
+package wrapper
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff describes an exponential backoff with jitter, it's used between reconnect attempts of the socket and between retries of http requests
+// Any field left at zero uses the value from DefaultBackoff
+type Backoff struct {
+	// Min is the delay before the first attempt
+	Min time.Duration
+	// Max caps the delay no matter how many attempts have been made
+	Max time.Duration
+	// Factor is what the delay is multiplied by after every attempt
+	Factor float64
+	// Jitter is the fraction of the delay that is randomised, 0.5 means the delay is anywhere between 50% and 100% of the computed value
+	Jitter float64
+}
+
+// DefaultBackoff is the backoff used when a Session doesn't set one, it starts at one second and caps at a minute
+var DefaultBackoff = Backoff{
+	Min:    time.Second,
+	Max:    time.Minute,
+	Factor: 2,
+	Jitter: 0.5,
+}
+
+// Delay returns how long to wait before the given attempt, attempt starts at 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Min <= 0 {
+		b.Min = DefaultBackoff.Min
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultBackoff.Max
+	}
+	if b.Factor < 1 {
+		b.Factor = DefaultBackoff.Factor
+	}
+	if b.Jitter <= 0 || b.Jitter > 1 {
+		b.Jitter = DefaultBackoff.Jitter
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(b.Min) * math.Pow(b.Factor, float64(attempt-1))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	d -= d * b.Jitter * rand.Float64()
+	return time.Duration(d)
+}
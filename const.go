@@ -1,24 +1,51 @@
 package wrapper
 
 const (
+	// DefaultOrigin is the http origin rustchance.com serves its website and api from
+	DefaultOrigin = "https://rustchance.com"
 	// SocketURL Is the url for the websocket that rustchance.com uses
 	SocketURL = "wss://rustchance.com/feed"
 	// AccountLeaderboardURL is the url for the account leader board
-	AccountLeaderboardURL = "https://rustchance.com/api/account/leaderboard"
+	AccountLeaderboardURL = DefaultOrigin + AccountLeaderboardPath
 	// TicketsLeaderboardURL is the url for the tickets leaderboard data
-	TicketsLeaderboardURL = "https://rustchance.com/api/bonuses"
+	TicketsLeaderboardURL = DefaultOrigin + TicketsLeaderboardPath
 	// AccountEarningsURL is the url to get the total account earning of an account
-	AccountEarningsURL = "https://rustchance.com/api/account/stats/all"
+	AccountEarningsURL = DefaultOrigin + AccountEarningsPath
 	// AccountProfileURL is the url to get the account profile, right now we use this to get the json of user information
-	AccountProfileURL = "https://rustchance.com/profile"
+	AccountProfileURL = DefaultOrigin + AccountProfilePath
 	// FaucetClaimURL is the url to claim the free 3 cent faucet
-	FaucetClaimURL = "https://rustchance.com/api/account/faucet"
+	FaucetClaimURL = DefaultOrigin + FaucetClaimPath
 	// ProvefairSerialURL is the url to check the validity of a "provably fair" action
-	ProvefairSerialURL = "https://rustchance.com/api/serial/"
+	ProvefairSerialURL = DefaultOrigin + ProvefairSerialPath
 	// RedeemCodeURL is the url to redeem a sponsor code
-	RedeemCodeURL = "https://rustchance.com/api/affiliates/redeem"
+	RedeemCodeURL = DefaultOrigin + RedeemCodePath
 	// HistoryAPIURL is the url to fetch the history of various gamemodes
-	HistoryAPIURL = "https://rustchance.com/api/history/"
+	HistoryAPIURL = DefaultOrigin + HistoryAPIPath
 	// CrashGameURL is the url to get information about a coinflip game
-	CrashGameURL = "https://rustchance.com/api/crash/game/"
+	CrashGameURL = DefaultOrigin + CrashGamePath
 )
+
+// The paths below are joined onto Endpoints.HTTP for every http request a Session makes
+const (
+	// AccountLeaderboardPath is the path of AccountLeaderboardURL
+	AccountLeaderboardPath = "/api/account/leaderboard"
+	// TicketsLeaderboardPath is the path of TicketsLeaderboardURL
+	TicketsLeaderboardPath = "/api/bonuses"
+	// AccountEarningsPath is the path of AccountEarningsURL
+	AccountEarningsPath = "/api/account/stats/all"
+	// AccountProfilePath is the path of AccountProfileURL
+	AccountProfilePath = "/profile"
+	// FaucetClaimPath is the path of FaucetClaimURL
+	FaucetClaimPath = "/api/account/faucet"
+	// ProvefairSerialPath is the path of ProvefairSerialURL
+	ProvefairSerialPath = "/api/serial/"
+	// RedeemCodePath is the path of RedeemCodeURL
+	RedeemCodePath = "/api/affiliates/redeem"
+	// HistoryAPIPath is the path of HistoryAPIURL
+	HistoryAPIPath = "/api/history/"
+	// CrashGamePath is the path of CrashGameURL
+	CrashGamePath = "/api/crash/game/"
+)
+
+// DefaultUserAgent is the User-Agent the socket and http requests are sent with unless WithUserAgent or WithHeaders sets one
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36 OPR/73.0.3856.421"
@@ -0,0 +1,94 @@
+package wrapper_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+func TestOpenAfterHTTP2Request(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed" {
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		w.Write([]byte(mock.TicketsLeaderboardJSON))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	s, err := wrapper.NewSession(
+		wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL, Socket: "wss" + strings.TrimPrefix(srv.URL, "https") + "/feed"}),
+		wrapper.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.GetLeaderboard(); err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open after an http/2 request: %v", err)
+	}
+}
+
+func TestDialFunc(t *testing.T) {
+	for _, dialerFirst := range []bool{true, false} {
+		srv := mock.NewServer()
+		var dials int32
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+		opts := []wrapper.Option{wrapper.WithDialer(websocket.DefaultDialer), wrapper.WithDialFunc(dial)}
+		if !dialerFirst {
+			opts[0], opts[1] = opts[1], opts[0]
+		}
+		s := newSession(t, srv, opts...)
+		if err := s.Open(); err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		s.Close()
+		srv.Close()
+		if n := atomic.LoadInt32(&dials); n != 1 {
+			t.Fatalf("dial func called %d times with WithDialer first %v, want 1", n, dialerFirst)
+		}
+		if websocket.DefaultDialer.NetDialContext != nil {
+			t.Fatal("WithDialFunc changed websocket.DefaultDialer")
+		}
+	}
+}
+
+func TestWithProxyKeepsCallerClient(t *testing.T) {
+	transport := &http.Transport{}
+	c := &http.Client{Transport: transport}
+	s, err := wrapper.NewSession(wrapper.WithHTTPClient(c), wrapper.WithProxy("http://127.0.0.1:8080"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Transport != transport || transport.Proxy != nil {
+		t.Fatal("WithProxy changed the client passed to WithHTTPClient")
+	}
+	if s.HTTPClient == c {
+		t.Fatal("WithProxy didn't copy the client")
+	}
+}
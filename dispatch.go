@@ -0,0 +1,131 @@
+package wrapper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DispatchMode decides how handlers are called when an event arrives
+type DispatchMode int
+
+const (
+	// DispatchSync calls handlers on the read loop, a slow handler holds up every event after it, this is the default
+	DispatchSync DispatchMode = iota
+	// DispatchGoroutine calls the handlers of every event in a new goroutine, events can be handled out of order
+	DispatchGoroutine
+	// DispatchPool calls handlers on a fixed number of workers, events of the same room always go to the same worker so they're handled in order
+	DispatchPool
+)
+
+// DefaultWorkerQueue is how many events a pool worker can have waiting before the read loop waits on it
+const DefaultWorkerQueue = 256
+
+// deliver runs fn the way Session.Dispatch says, key is the event key and its room decides the pool worker
+func (s *Session) deliver(key string, fn func()) {
+	switch s.Dispatch {
+	case DispatchGoroutine:
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			fn()
+		}()
+	case DispatchPool:
+		s.poolMu.RLock()
+		if s.pool == nil {
+			s.poolMu.RUnlock()
+			s.poolMu.Lock()
+			if s.pool == nil {
+				s.pool = newWorkerPool(s.Workers, s.WorkerQueue)
+			}
+			s.poolMu.Unlock()
+			s.poolMu.RLock()
+		}
+		s.pool.submit(roomOf(key), fn)
+		s.poolMu.RUnlock()
+	default:
+		fn()
+	}
+}
+
+// stopPool lets the pool workers finish what's queued and stops them, a later event starts a new pool
+func (s *Session) stopPool() {
+	s.poolMu.Lock()
+	p := s.pool
+	s.pool = nil
+	s.poolMu.Unlock()
+	if p != nil {
+		p.stop()
+	}
+}
+
+// roomOf returns the room part of an event key, "crash_tick" is in room "crash"
+func roomOf(key string) string {
+	if i := strings.Index(key, "_"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// callHandlers calls every handler in hs with v, a handler that panics is recovered so it doesn't take the read loop down with it
+func callHandlers(s *Session, hs []*eventHandler, v interface{}) {
+	for _, h := range hs {
+		callHandler(s, h, v)
+	}
+}
+
+// callHandler calls a single handler and recovers from a panic in it
+func callHandler(s *Session, h *eventHandler, v interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger().Error("handler panicked", "event", fmt.Sprintf("%T", v), "panic", r)
+		}
+	}()
+	h.fn(s, v)
+}
+
+// workerPool is a fixed set of goroutines each with its own queue, a room always hashes to the same queue
+type workerPool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// newWorkerPool starts workers goroutines with a queue of queue funcs each, zero values pick runtime.NumCPU() and DefaultWorkerQueue
+func newWorkerPool(workers, queue int) *workerPool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if queue <= 0 {
+		queue = DefaultWorkerQueue
+	}
+	p := &workerPool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		q := make(chan func(), queue)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for fn := range q {
+				fn()
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues fn on the worker for room, it waits when that worker's queue is full
+func (p *workerPool) submit(room string, fn func()) {
+	h := fnv.New32a()
+	h.Write([]byte(room))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- fn
+}
+
+// stop closes the queues and waits for the workers to drain them
+func (p *workerPool) stop() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,288 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DriftDetector compares payloads against the structs they're decoded into and collects where they don't line up
+// It's off by default, set Session.Drift to a detector from NewDriftDetector to check every socket payload and http response
+// The check works like decoding with DisallowUnknownFields except it doesn't stop at the first problem, it walks the whole payload
+type DriftDetector struct {
+	mu      sync.Mutex
+	reports map[string]*DriftReport
+}
+
+// DriftReport is what a DriftDetector found for one event type or http endpoint
+type DriftReport struct {
+	// Event is the socket event key like "crash_new" or the http path like "/api/history/coinflip"
+	Event string `json:"event"`
+	// Samples is how many payloads were checked
+	Samples int `json:"samples"`
+	// Unmapped is true for socket events that have no registered struct at all
+	Unmapped bool `json:"unmapped,omitempty"`
+	// UnknownFields counts fields in the payload that the struct doesn't have, keyed by path like "data.bets[].x"
+	UnknownFields map[string]int `json:"unknownFields,omitempty"`
+	// MissingFields counts struct fields that weren't in the payload, keyed by path
+	MissingFields map[string]int `json:"missingFields,omitempty"`
+	// TypeMismatches holds the last mismatch seen for a path, like "got string, want int"
+	TypeMismatches map[string]string `json:"typeMismatches,omitempty"`
+}
+
+// NewDriftDetector returns an empty detector
+func NewDriftDetector() *DriftDetector {
+	return &DriftDetector{reports: make(map[string]*DriftReport)}
+}
+
+// Check compares raw against the type of v, v is usually the value raw was decoded into, for example a *CrashNew
+// A nil v records raw as an unmapped event
+func (d *DriftDetector) Check(event string, raw []byte, v interface{}) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.reports[event]
+	if r == nil {
+		r = &DriftReport{Event: event}
+		d.reports[event] = r
+	}
+	r.Samples++
+	if v == nil {
+		r.Unmapped = true
+		return
+	}
+	compareSchema(r, "", data, reflect.TypeOf(v))
+}
+
+// Reports returns a copy of every report sorted by event
+func (d *DriftDetector) Reports() []DriftReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DriftReport, 0, len(d.reports))
+	for _, r := range d.reports {
+		c := DriftReport{
+			Event:          r.Event,
+			Samples:        r.Samples,
+			Unmapped:       r.Unmapped,
+			UnknownFields:  copyCounts(r.UnknownFields),
+			MissingFields:  copyCounts(r.MissingFields),
+			TypeMismatches: make(map[string]string, len(r.TypeMismatches)),
+		}
+		for k, v := range r.TypeMismatches {
+			c.TypeMismatches[k] = v
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Event < out[j].Event
+	})
+	return out
+}
+
+// Drifted returns only the reports that found something, unmapped events included
+func (d *DriftDetector) Drifted() []DriftReport {
+	var out []DriftReport
+	for _, r := range d.Reports() {
+		if r.Unmapped || len(r.UnknownFields) > 0 || len(r.MissingFields) > 0 || len(r.TypeMismatches) > 0 {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// JSON returns every report as indented json, ready to be written to a file
+func (d *DriftDetector) JSON() ([]byte, error) {
+	return json.MarshalIndent(d.Reports(), "", "  ")
+}
+
+// Reset throws away everything collected so far
+func (d *DriftDetector) Reset() {
+	d.mu.Lock()
+	d.reports = make(map[string]*DriftReport)
+	d.mu.Unlock()
+}
+
+func copyCounts(m map[string]int) map[string]int {
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// unmarshalerType is used to skip types that decode themselves, like time.Time
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// compareSchema walks data, which was decoded with UseNumber, alongside t and records every difference in r
+func compareSchema(r *DriftReport, path string, data interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if data == nil || t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			mismatch(r, path, data, t)
+			return
+		}
+		seen := make(map[string]bool, len(obj))
+		for _, f := range jsonFields(t) {
+			key, v, ok := lookupField(obj, f.name)
+			if !ok {
+				// an omitempty field like the error of a response is only there sometimes
+				if f.optional {
+					continue
+				}
+				if r.MissingFields == nil {
+					r.MissingFields = make(map[string]int)
+				}
+				r.MissingFields[joinPath(path, f.name)]++
+				continue
+			}
+			seen[key] = true
+			compareSchema(r, joinPath(path, f.name), v, f.typ)
+		}
+		for key := range obj {
+			if !seen[key] {
+				if r.UnknownFields == nil {
+					r.UnknownFields = make(map[string]int)
+				}
+				r.UnknownFields[joinPath(path, key)]++
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := data.([]interface{})
+		if !ok {
+			if _, isString := data.(string); isString && t.Elem().Kind() == reflect.Uint8 {
+				return
+			}
+			mismatch(r, path, data, t)
+			return
+		}
+		for _, v := range arr {
+			compareSchema(r, path+"[]", v, t.Elem())
+		}
+	case reflect.Map:
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			mismatch(r, path, data, t)
+			return
+		}
+		for _, v := range obj {
+			compareSchema(r, joinPath(path, "*"), v, t.Elem())
+		}
+	case reflect.String:
+		if _, ok := data.(string); !ok {
+			mismatch(r, path, data, t)
+		}
+	case reflect.Bool:
+		if _, ok := data.(bool); !ok {
+			mismatch(r, path, data, t)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := data.(json.Number)
+		if !ok {
+			mismatch(r, path, data, t)
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			mismatch(r, path, data, t)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := data.(json.Number); !ok {
+			mismatch(r, path, data, t)
+		}
+	}
+}
+
+// mismatch records that the json at path doesn't fit t
+func mismatch(r *DriftReport, path string, data interface{}, t reflect.Type) {
+	if r.TypeMismatches == nil {
+		r.TypeMismatches = make(map[string]string)
+	}
+	got := "object"
+	switch v := data.(type) {
+	case string:
+		got = "string"
+	case bool:
+		got = "bool"
+	case json.Number:
+		got = "number " + v.String()
+	case []interface{}:
+		got = "array"
+	}
+	r.TypeMismatches[path] = fmt.Sprintf("got %s, want %s", got, t.String())
+}
+
+// jsonField is a struct field as encoding/json sees it
+type jsonField struct {
+	name string
+	typ  reflect.Type
+	// optional is set for omitempty fields, they aren't missing when a payload leaves them out
+	optional bool
+}
+
+// jsonFields lists the fields encoding/json would decode into for t, embedded structs are flattened
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: f.Type, optional: contains(opts[1:], "omitempty")})
+	}
+	return fields
+}
+
+// lookupField finds name in obj the way encoding/json does, an exact match first and then a case insensitive one
+func lookupField(obj map[string]interface{}, name string) (string, interface{}, bool) {
+	if v, ok := obj[name]; ok {
+		return name, v, true
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, name) {
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
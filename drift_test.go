@@ -0,0 +1,80 @@
+package wrapper_test
+
+import (
+	"testing"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+func TestDriftCleanResponses(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(srv.Endpoints()), wrapper.WithToken("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Drift = wrapper.NewDriftDetector()
+	// successful responses leave out the error field
+	if _, err := s.ClaimFaucet("captcha"); err != nil {
+		t.Fatalf("ClaimFaucet: %v", err)
+	}
+	if _, err := s.RedeemCode("code"); err != nil {
+		t.Fatalf("RedeemCode: %v", err)
+	}
+	if _, err := s.CheckSerial("1"); err != nil {
+		t.Fatalf("CheckSerial: %v", err)
+	}
+	if n := len(s.Drift.Reports()); n != 3 {
+		t.Fatalf("%d reports, want one per endpoint", n)
+	}
+	if d := s.Drift.Drifted(); len(d) != 0 {
+		t.Fatalf("drift in responses that match their structs: %+v", d)
+	}
+}
+
+func TestDriftCheck(t *testing.T) {
+	d := wrapper.NewDriftDetector()
+	d.Check("faucet", []byte(`{"success":true,"result":3}`), &wrapper.FaucetResponse{})
+	d.Check("faucet", []byte(`{"success":false,"error":"too soon","result":0}`), &wrapper.FaucetResponse{})
+	d.Check("redeem", []byte(`{"success":true,"extra":1}`), &wrapper.RedeemCodeResponse{})
+	d.Check("serial", []byte(`{"success":true,"result":{"number":"1"}}`), &wrapper.ProvablyFair{})
+	d.Check("mystery", []byte(`{"data":1}`), nil)
+	// bad json isn't counted at all
+	d.Check("broken", []byte(`{`), &wrapper.FaucetResponse{})
+
+	reports := map[string]wrapper.DriftReport{}
+	for _, r := range d.Reports() {
+		reports[r.Event] = r
+	}
+	if len(reports) != 4 {
+		t.Fatalf("reports for %v, want faucet, redeem, serial and mystery", reports)
+	}
+	if r := reports["faucet"]; r.Samples != 2 || len(r.UnknownFields)+len(r.MissingFields)+len(r.TypeMismatches) != 0 {
+		t.Fatalf("faucet report = %+v, want 2 clean samples", r)
+	}
+	redeem := reports["redeem"]
+	if redeem.UnknownFields["extra"] != 1 {
+		t.Fatalf("redeem unknown fields = %v, want extra", redeem.UnknownFields)
+	}
+	if redeem.MissingFields["message"] != 1 || len(redeem.MissingFields) != 1 {
+		t.Fatalf("redeem missing fields = %v, want only message", redeem.MissingFields)
+	}
+	serial := reports["serial"]
+	if _, ok := serial.TypeMismatches["result.number"]; !ok {
+		t.Fatalf("serial type mismatches = %v, want result.number", serial.TypeMismatches)
+	}
+	if serial.MissingFields["result.seed"] != 1 {
+		t.Fatalf("serial missing fields = %v, want result.seed among them", serial.MissingFields)
+	}
+	if !reports["mystery"].Unmapped {
+		t.Fatal("an event checked without a struct isn't reported as unmapped")
+	}
+	if n := len(d.Drifted()); n != 3 {
+		t.Fatalf("%d drifted reports, want redeem, serial and mystery", n)
+	}
+	d.Reset()
+	if n := len(d.Reports()); n != 0 {
+		t.Fatalf("%d reports after Reset", n)
+	}
+}
@@ -0,0 +1,89 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNoAuthToken is returned by anything that needs an auth token when Session.Auth is blank
+	ErrNoAuthToken = errors.New("no auth token set")
+	// ErrUnauthorized is matched by an *APIError when rustchance rejected the auth token or the request needed one
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited is matched by an *APIError when rustchance answered with 429 Too Many Requests and by a *RateLimitError
+	ErrRateLimited = errors.New("rate limited")
+	// ErrAlreadyOpen is returned by Open when the session already has a running socket, call Close first
+	ErrAlreadyOpen = errors.New("socket is already open")
+)
+
+// APIError is returned when rustchance answers with a non 200 status code or a payload with success set to false
+// Use errors.Is with ErrUnauthorized or ErrRateLimited to check what kind of failure it was, or errors.As to get at the fields
+type APIError struct {
+	// StatusCode is the http status code of the response, it's 200 when the failure was a success:false payload
+	StatusCode int
+	// Endpoint is the path of the request that failed, for example "/api/account/faucet"
+	Endpoint string
+	// Message is the error message rustchance sent back, if it didn't send one this is the http status text
+	Message string
+	// Err is the sentinel error this failure matches, ErrUnauthorized, ErrRateLimited or nil
+	Err error
+	// RetryAfter is how long rustchance asked to wait before trying again, it's only set on 429 and on 503 with a Retry-After header
+	RetryAfter time.Duration
+}
+
+// Error formats the error like "/api/bonuses failed with code 429: Too Many Requests"
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed with code %d: %s", e.Endpoint, e.StatusCode, e.Message)
+}
+
+// Unwrap returns the sentinel error this failure matches so errors.Is works
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// apiErrorBody is the shape rustchance uses for error messages in json bodies
+type apiErrorBody struct {
+	Err     string `json:"error"`
+	Message string `json:"message"`
+}
+
+// newAPIError builds an *APIError from a status code and an optional json body, the sentinel is picked from the status code
+func newAPIError(statusCode int, endpoint string, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: statusCode,
+		Endpoint:   endpoint,
+	}
+	var b apiErrorBody
+	if json.Unmarshal(body, &b) == nil {
+		e.Message = b.Err
+		if e.Message == "" {
+			e.Message = b.Message
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(statusCode)
+	}
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		e.Err = ErrUnauthorized
+	case http.StatusTooManyRequests:
+		e.Err = ErrRateLimited
+	}
+	return e
+}
+
+// unsuccessful builds the *APIError for a 200 response whose payload had success set to false
+// msg is the error field of the payload, if it's blank "success was false" is used
+func unsuccessful(endpoint, msg string) *APIError {
+	if msg == "" {
+		msg = "success was false"
+	}
+	return &APIError{
+		StatusCode: http.StatusOK,
+		Endpoint:   endpoint,
+		Message:    msg,
+	}
+}
@@ -0,0 +1,203 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// EventType describes one kind of event, how a socket payload is decoded into it and how a handler for it is called
+// Most events can be registered with RegisterEvent, RegisterEventType is there when you want full control over decoding
+type EventType struct {
+	// Name is the room and type of the payload joined by an underscore, for example "crash_tick"
+	Name string
+	// Decode turns a raw payload into the value handlers are called with, it's nil for events that don't come from the socket like Connected
+	Decode func(raw []byte) (interface{}, error)
+	// Adapt wraps a handler passed to AddHandler into one that takes an interface{}, it returns nil when the handler isn't for this event
+	Adapt func(handler interface{}) func(*Session, interface{})
+}
+
+var (
+	// eventsMu guards eventsByName and eventOrder
+	eventsMu sync.RWMutex
+	// eventsByName maps an event name to its type
+	eventsByName = map[string]*EventType{}
+	// eventOrder is the registration order, AddHandler checks events in this order so the first registration of a struct wins
+	eventOrder []*EventType
+	// sessionType is the type of the first argument of every handler
+	sessionType = reflect.TypeOf(&Session{})
+)
+
+// builtinEvents are the socket events this package knows about, the key is the room and type of the payload and the value is the struct it's decoded into
+var builtinEvents = []struct {
+	name      string
+	prototype interface{}
+}{
+	{"shop_rules", &ShopRules{}},
+	{"chat_rooms", &ChatRooms{}},
+	{"chat_message", &ChatMessage{}},
+	{"chat_stats", &ChatStats{}},
+	{"coinflip_delete_game", &CoinflipDeleteGame{}},
+	{"coinflip_game_status", &CoinflipGameStatus{}},
+	{"coinflip_list", &CoinflipList{}},
+	{"coinflip_new_game", &CoinflipNewGame{}},
+	{"coinflip_update_game", &CoinflipUpdateGame{}},
+	{"crash_cashout", &CrashCashOut{}},
+	{"crash_multiple_bets", &CrashMultipleBets{}},
+	{"crash_new", &CrashNew{}},
+	{"crash_start", &CrashStart{}},
+	{"crash_tick", &CrashTick{}},
+	{"jackpot_list", &JackpotList{}},
+	{"jackpot_new_deposit", &JackpotNewDeposit{}},
+	{"jackpot_new_game", &JackpotNewGame{}},
+	{"jackpot_start_timer", &JackpotStartTimer{}},
+	{"jackpot-low_list", &LowJackpotList{}},
+	{"jackpot-low_new_deposit", &LowJackpotNewDeposit{}},
+	{"jackpot-low_new_game", &LowJackpotNewGame{}},
+	{"jackpot-low_start_timer", &LowJackpotStartTimer{}},
+	{"mines_begin_timer", &MinesBeginTimer{}},
+	{"mines_game_started", &MinesGameStarted{}},
+	{"mines_game_starting", &MinesGameStarting{}},
+	{"mines_list", &MinesList{}},
+	{"mines_new_game", &MinesNewGame{}},
+	{"mines_new_player", &MinesNewPlayer{}},
+	{"mines_winner", &MinesWinner{}},
+	{"supply-drops_joinable", &SupplyDropsJoinable{}},
+	{"supply-drops_list", &SupplyDropsList{}},
+	{"supply-drops_players", &SupplyDropsPlayers{}},
+	{"supply-drops_result", &SupplyDropWinner{}},
+	{"roulette_roll", &RouletteRoll{}},
+	{"roulette_list", &RouletteList{}},
+	{"user_set_points", &UserSetPoints{}},
+	{"crash_end", &CrashEnd{}},
+	{"crash_list", &CrashList{}},
+}
+
+// localEvents are events the session makes up itself, they are never decoded from the socket
+var localEvents = []struct {
+	name      string
+	prototype interface{}
+}{
+	{"socket_connected", &Connected{}},
+	{"socket_disconnected", &Disconnected{}},
+	{"socket_reconnecting", &Reconnecting{}},
+	{"socket_resumed", &Resumed{}},
+	{"socket_raw", &RawEvent{}},
+	{"socket_unknown", &UnknownEvent{}},
+}
+
+func init() {
+	for _, e := range builtinEvents {
+		if err := RegisterEvent(e.name, e.prototype); err != nil {
+			panic(err)
+		}
+	}
+	for _, e := range localEvents {
+		et := reflectEventType(e.name, e.prototype)
+		et.Decode = nil
+		if err := RegisterEventType(et); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterEvent registers a socket event so its payloads are decoded and passed to handlers added with AddHandler
+// Name is the room and type of the payload joined by an underscore, for example "shop_update"
+// Prototype is a pointer to the struct payloads are decoded into, for example &ShopUpdate{}, handlers for it look like func(*Session, *ShopUpdate)
+func RegisterEvent(name string, prototype interface{}) error {
+	t := reflect.TypeOf(prototype)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return errors.New("event prototype must be a pointer")
+	}
+	probe := reflect.Zero(reflect.FuncOf([]reflect.Type{sessionType, t}, nil, false)).Interface()
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for _, et := range eventOrder {
+		if et.Adapt(probe) != nil {
+			return errors.New("event prototype " + t.String() + " is already registered as " + et.Name)
+		}
+	}
+	return registerEventType(reflectEventType(name, prototype))
+}
+
+// RegisterEventType registers an event with its own decoder and handler adapter, it errors if the name is already taken
+func RegisterEventType(et EventType) error {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	return registerEventType(et)
+}
+
+// registerEventType adds et to the registry, eventsMu must be held
+func registerEventType(et EventType) error {
+	if et.Name == "" {
+		return errors.New("event name can't be blank")
+	}
+	if et.Adapt == nil {
+		return errors.New("event " + et.Name + " has no Adapt func")
+	}
+	if _, ok := eventsByName[et.Name]; ok {
+		return errors.New("event " + et.Name + " is already registered")
+	}
+	eventsByName[et.Name] = &et
+	eventOrder = append(eventOrder, &et)
+	return nil
+}
+
+// RegisteredEvents returns the names of every registered socket event sorted alphabetically
+func RegisteredEvents() []string {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	names := make([]string, 0, len(eventsByName))
+	for name, et := range eventsByName {
+		if et.Decode != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupEvent returns the event registered under name
+func lookupEvent(name string) (*EventType, bool) {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	et, ok := eventsByName[name]
+	return et, ok
+}
+
+// adaptHandler finds the event a handler passed to AddHandler is for and wraps the handler, fn is nil when no event matches
+func adaptHandler(handler interface{}) (name string, fn func(*Session, interface{})) {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	for _, et := range eventOrder {
+		if fn := et.Adapt(handler); fn != nil {
+			return et.Name, fn
+		}
+	}
+	return "", nil
+}
+
+// reflectEventType builds an EventType that decodes payloads with encoding/json into a new value of prototype's type and calls handlers of the form func(*Session, T)
+func reflectEventType(name string, prototype interface{}) EventType {
+	t := reflect.TypeOf(prototype)
+	want := reflect.FuncOf([]reflect.Type{sessionType, t}, nil, false)
+	return EventType{
+		Name: name,
+		Decode: func(raw []byte) (interface{}, error) {
+			v := reflect.New(t.Elem()).Interface()
+			err := json.Unmarshal(raw, v)
+			return v, err
+		},
+		Adapt: func(handler interface{}) func(*Session, interface{}) {
+			h := reflect.ValueOf(handler)
+			if !h.IsValid() || h.Type() != want {
+				return nil
+			}
+			return func(s *Session, v interface{}) {
+				h.Call([]reflect.Value{reflect.ValueOf(s), reflect.ValueOf(v)})
+			}
+		},
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+)
+
+func main() {
+	session, err := wrapper.NewSession(wrapper.WithToken("token") /*you need a token for account specific http*/)
+	if err != nil {
+		panic(err)
+	}
+	earnings, err := session.AccountEarnings()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%+v\n", earnings)
+}
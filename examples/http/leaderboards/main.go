@@ -7,7 +7,7 @@ import (
 )
 
 func main() {
-	session, err := wrapper.New("token" /*this can be blank, if it is blank it will not be able to use auth restricted http*/, []string{ /*I input nothing because I wanted it to default to listen for all*/ }, "" /*again I input nothing so it will default to EN*/)
+	session, err := wrapper.NewSession(wrapper.WithToken("token") /*this can be left out, without it AccountLeaderboard will error but GetLeaderboard still works*/)
 	if err != nil {
 		panic(err)
 	}
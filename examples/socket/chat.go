@@ -7,7 +7,7 @@ import (
 )
 
 func getRooms(session *wrapper.Session, rooms *wrapper.ChatRooms) {
-	session.SwitchRoom("en")
+	session.SwitchChatRoom("en")
 }
 
 func onMessage(session *wrapper.Session, message *wrapper.ChatMessage) {
@@ -15,7 +15,11 @@ func onMessage(session *wrapper.Session, message *wrapper.ChatMessage) {
 }
 
 func main() {
-	session, err := wrapper.New("token" /*this can be blank, if it is blank it will not be able to use auth restricted http*/, []string{ /*I input nothing because I wanted it to default to listen for all*/ }, "" /*again I input nothing so it will default to EN*/)
+	session, err := wrapper.NewSession(
+		wrapper.WithToken("token"), /*this can be left out, without it the socket is unauthorized*/
+		wrapper.WithRooms("chat"),  /*leave this out to join every room*/
+		wrapper.WithChatRoom("en"),
+	)
 	if err != nil {
 		panic(err)
 	}
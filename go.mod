@@ -2,4 +2,4 @@ module github.com/post04/rustchance-api-wrapper
 
 go 1.16
 
-require github.com/gorilla/websocket v1.4.2 // indirect
+require github.com/gorilla/websocket v1.4.2
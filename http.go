@@ -1,6 +1,7 @@
 package wrapper
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,52 +12,91 @@ import (
 )
 
 // MakeRequest builds a new request, it's to chop down on reused code
+// URL can either be a full url or a path like "/api/bonuses", paths are joined onto the session's http endpoint
 func (s *Session) MakeRequest(auth bool, method, URL string, body *strings.Reader) (*http.Request, error) {
+	return s.MakeRequestCtx(context.Background(), auth, method, URL, body)
+}
+
+// MakeRequestCtx is MakeRequest but the request is bound to ctx, cancelling ctx aborts the request
+func (s *Session) MakeRequestCtx(ctx context.Context, auth bool, method, URL string, body *strings.Reader) (*http.Request, error) {
+	if strings.HasPrefix(URL, "/") {
+		URL = s.httpURL(URL)
+	}
+	var r io.Reader
+	if body != nil {
+		r = body
+	}
+	req, err := http.NewRequestWithContext(ctx, method, URL, r)
+	if err != nil {
+		return nil, err
+	}
+	if ua := s.Headers.Get("User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
 	if auth {
 		if s.Auth == "" {
-			return nil, errors.New("no auth token set")
-		}
-		var req *http.Request
-		var err error
-		if body == nil {
-			req, err = http.NewRequest(method, URL, nil)
-		} else {
-			req, err = http.NewRequest(method, URL, body)
-		}
-		if err != nil {
-			return nil, err
+			return nil, ErrNoAuthToken
 		}
 		req.Header.Set("cookie", "token="+s.Auth)
-		return req, nil
-	}
-	req, err := http.NewRequest(method, URL, body)
-	if err != nil {
-		return nil, err
 	}
 	return req, nil
 }
 
 // GetBody does all the misc checking and returns the byte body of an http request
+// A non 200 status code is returned as an *APIError carrying the error message from the body if there is one
+// The request waits for the rate limit of its endpoint family first, a 429 holds the family back for the Retry-After the response asked for
+// GET requests that fail on the connection or with a status like 503 are retried following Session.Retry, other methods are only ever sent once
 func (s *Session) GetBody(req *http.Request) ([]byte, error) {
-	c := http.Client{}
-	resp, err := c.Do(req)
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		b, err := s.send(req)
+		if err == nil {
+			return b, nil
+		}
+		if attempt >= s.Retry.MaxAttempts || !idempotent(req) || !s.Retry.retryable(ctx, err) {
+			return []byte{}, err
+		}
+		delay := s.Retry.delay(attempt, err)
+		s.logger().Warn("retrying request", "endpoint", req.URL.Path, "attempt", attempt, "delay", delay, "error", err)
+		if !sleepRetry(ctx, delay) {
+			return []byte{}, err
+		}
+	}
+}
+
+// send makes a single attempt at req for GetBody
+func (s *Session) send(req *http.Request) ([]byte, error) {
+	if err := s.waitRateLimit(req.Context(), req.URL.Path); err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient().Do(req)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return []byte{}, errors.New("http request failed with code " + resp.Status)
-	}
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		e := newAPIError(resp.StatusCode, req.URL.Path, b)
+		if resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "") {
+			e.RetryAfter = retryAfter(resp, DefaultRetryAfter)
+			s.holdBack(req.URL.Path, e.RetryAfter)
+		}
+		return nil, e
 	}
 	return b, nil
 }
 
 // AllInOneHTTP is just a wrap of MakeRequest and GetBody to chop down on reused code while letting me keep control in the future
 func (s *Session) AllInOneHTTP(auth bool, Method, URL string, body *strings.Reader) ([]byte, error) {
-	req, err := s.MakeRequest(auth, Method, URL, body)
+	return s.AllInOneHTTPCtx(context.Background(), auth, Method, URL, body)
+}
+
+// AllInOneHTTPCtx is AllInOneHTTP with a context
+func (s *Session) AllInOneHTTPCtx(ctx context.Context, auth bool, Method, URL string, body *strings.Reader) ([]byte, error) {
+	req, err := s.MakeRequestCtx(ctx, auth, Method, URL, body)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -67,14 +107,43 @@ func (s *Session) AllInOneHTTP(auth bool, Method, URL string, body *strings.Read
 	return b, nil
 }
 
+// PostForm sends an authorized url encoded form to URL and returns the body, it goes through GetBody so a non 200 status code is an *APIError
+func (s *Session) PostForm(URL, form string) ([]byte, error) {
+	return s.PostFormCtx(context.Background(), URL, form)
+}
+
+// PostFormCtx is PostForm with a context
+func (s *Session) PostFormCtx(ctx context.Context, URL, form string) ([]byte, error) {
+	req, err := s.MakeRequestCtx(ctx, true, "POST", URL, strings.NewReader(form))
+	if err != nil {
+		return []byte{}, err
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded; charset=UTF-8")
+	return s.GetBody(req)
+}
+
+// decode unmarshals an http response into v and hands it to Session.Drift when drift detection is on, endpoint names the response in the drift report
+func (s *Session) decode(endpoint string, b []byte, v interface{}) error {
+	err := json.Unmarshal(b, v)
+	if s.Drift != nil {
+		s.Drift.Check(endpoint, b, v)
+	}
+	return err
+}
+
 // AccountLeaderboard gets the current accounts leaderboard position in the tickets leaderboard, this requires an authorization token to be set and **WILL** error if one is not provided
 func (s *Session) AccountLeaderboard() (*AccountLeaderboard, error) {
-	b, err := s.AllInOneHTTP(true, "GET", AccountLeaderboardURL, nil)
+	return s.AccountLeaderboardCtx(context.Background())
+}
+
+// AccountLeaderboardCtx is AccountLeaderboard with a context, cancelling ctx aborts the request
+func (s *Session) AccountLeaderboardCtx(ctx context.Context) (*AccountLeaderboard, error) {
+	b, err := s.AllInOneHTTPCtx(ctx, true, "GET", AccountLeaderboardPath, nil)
 	if err != nil {
 		return nil, err
 	}
 	r := &AccountLeaderboard{}
-	err = json.Unmarshal(b, &r)
+	err = s.decode(AccountLeaderboardPath, b, r)
 	if err != nil {
 		return nil, err
 	}
@@ -83,24 +152,34 @@ func (s *Session) AccountLeaderboard() (*AccountLeaderboard, error) {
 
 // GetLeaderboard fetches the data for the current users in the tickets leaderboard, this requires no authorization and therefor doesn't use any cookie headers
 func (s *Session) GetLeaderboard() (*TicketsLeaderboardResult, error) {
-	b, err := s.AllInOneHTTP(true, "GET", AccountLeaderboardURL, nil)
+	return s.GetLeaderboardCtx(context.Background())
+}
+
+// GetLeaderboardCtx is GetLeaderboard with a context, cancelling ctx aborts the request
+func (s *Session) GetLeaderboardCtx(ctx context.Context) (*TicketsLeaderboardResult, error) {
+	b, err := s.AllInOneHTTPCtx(ctx, false, "GET", TicketsLeaderboardPath, nil)
 	if err != nil {
 		return nil, err
 	}
 	r := &TicketsLeaderboard{}
-	err = json.Unmarshal(b, &r)
+	err = s.decode(TicketsLeaderboardPath, b, r)
 	if err != nil {
 		return nil, err
 	}
 	if !r.Success {
-		return nil, errors.New("success was false")
+		return nil, unsuccessful(TicketsLeaderboardPath, "")
 	}
 	return &r.Result, nil
 }
 
 // AccountEarnings is the func to get accounts earnings, it returns the amount of money put in and the amount of money won so you can calculate a profit amount. You **need** auth for this func, if auth isn't set it will give back an error
 func (s *Session) AccountEarnings() (*TotalWageredResult, error) {
-	req, err := s.MakeRequest(true, "GET", AccountEarningsURL, nil)
+	return s.AccountEarningsCtx(context.Background())
+}
+
+// AccountEarningsCtx is AccountEarnings with a context, cancelling ctx aborts the request
+func (s *Session) AccountEarningsCtx(ctx context.Context) (*TotalWageredResult, error) {
+	req, err := s.MakeRequestCtx(ctx, true, "GET", AccountEarningsPath, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -109,12 +188,12 @@ func (s *Session) AccountEarnings() (*TotalWageredResult, error) {
 		return nil, err
 	}
 	r := &TotalWagered{}
-	err = json.Unmarshal(b, &r)
+	err = s.decode(AccountEarningsPath, b, r)
 	if err != nil {
 		return nil, err
 	}
 	if !r.Success {
-		return nil, errors.New("success was false")
+		return nil, unsuccessful(AccountEarningsPath, "")
 	}
 	return &r.Result, nil
 }
@@ -136,7 +215,12 @@ func formatJSON(s string) string {
 
 // GetAccountInfo gets the general information of an account
 func (s *Session) GetAccountInfo() (*AccountInfo, error) {
-	req, err := s.MakeRequest(true, "GET", AccountProfileURL, nil)
+	return s.GetAccountInfoCtx(context.Background())
+}
+
+// GetAccountInfoCtx is GetAccountInfo with a context, cancelling ctx aborts the request
+func (s *Session) GetAccountInfoCtx(ctx context.Context) (*AccountInfo, error) {
+	req, err := s.MakeRequestCtx(ctx, true, "GET", AccountProfilePath, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -146,57 +230,70 @@ func (s *Session) GetAccountInfo() (*AccountInfo, error) {
 	}
 	matches := AccountJSONRegex.FindAllString(string(b), -1)
 	if len(matches) < 1 {
-		return nil, err
+		return nil, unsuccessful(AccountProfilePath, "no user data found in the profile page")
 	}
 	match := strings.ReplaceAll(matches[0], "window.userData=", "")
 	r := &AccountInfo{}
 	match = formatJSON(match)
-	err = json.Unmarshal([]byte(match), &r)
+	err = s.decode(AccountProfilePath, []byte(match), r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Auth {
+		return nil, &APIError{
+			StatusCode: http.StatusOK,
+			Endpoint:   AccountProfilePath,
+			Message:    "the profile page is not logged in",
+			Err:        ErrUnauthorized,
+		}
+	}
 	return r, nil
 }
 
 // ClaimFaucet attempts to claim the free 3 cent faucet
 // CaptchaToken is an hcaptcha token, you'd need to use 2captcha or a similar service to get this CaptchaToken
-// Response is a *FaucetResponse, followed by an error
+// Response is a *FaucetResponse, followed by an error. A failed claim is an *APIError with the reason rustchance gave in Message
 func (s *Session) ClaimFaucet(CaptchaToken string) (*FaucetResponse, error) {
-	req, err := http.NewRequest("POST", FaucetClaimURL, strings.NewReader("response="+CaptchaToken))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("cookie", "token="+s.Auth)
-	req.Header.Set("content-type", "application/x-www-form-urlencoded; charset=UTF-8")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
+	return s.ClaimFaucetCtx(context.Background(), CaptchaToken)
+}
+
+// ClaimFaucetCtx is ClaimFaucet with a context, cancelling ctx aborts the request
+func (s *Session) ClaimFaucetCtx(ctx context.Context, CaptchaToken string) (*FaucetResponse, error) {
+	b, err := s.PostFormCtx(ctx, FaucetClaimPath, "response="+CaptchaToken)
 	if err != nil {
 		return nil, err
 	}
 	r := &FaucetResponse{}
-	err = json.Unmarshal(b, &r)
+	err = s.decode(FaucetClaimPath, b, r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Success {
+		return nil, unsuccessful(FaucetClaimPath, r.Err)
+	}
 	return r, nil
 }
 
 // CheckSerial returns the "Provably fair" response from a URL like https://rustchance.com/provably-fair/serial?number=5465806
 // Serial with the example of that URL would be 5465806 aka the game number (not ID)
 func (s *Session) CheckSerial(Serial string) (*ProvablyFair, error) {
-	resp, err := s.AllInOneHTTP(false, "GET", ProvefairSerialURL+Serial, nil)
+	return s.CheckSerialCtx(context.Background(), Serial)
+}
+
+// CheckSerialCtx is CheckSerial with a context, cancelling ctx aborts the request
+func (s *Session) CheckSerialCtx(ctx context.Context, Serial string) (*ProvablyFair, error) {
+	resp, err := s.AllInOneHTTPCtx(ctx, false, "GET", ProvefairSerialPath+Serial, nil)
 	if err != nil {
 		return nil, err
 	}
 	r := &ProvablyFair{}
-	err = json.Unmarshal(resp, &r)
+	err = s.decode(ProvefairSerialPath, resp, r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Success {
+		return nil, unsuccessful(ProvefairSerialPath+Serial, r.Err)
+	}
 	return r, nil
 }
 
@@ -204,72 +301,97 @@ func (s *Session) CheckSerial(Serial string) (*ProvablyFair, error) {
 // Code is the sponsored code, for example, "CHANCE"
 // NOTE: I have no clue what a valid and successful response looks like...
 func (s *Session) RedeemCode(Code string) (*RedeemCodeResponse, error) {
-	req, err := http.NewRequest("POST", RedeemCodeURL, strings.NewReader("code="+Code))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("cookie", "token="+s.Auth)
-	req.Header.Set("content-type", "application/x-www-form-urlencoded; charset=UTF-8")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
+	return s.RedeemCodeCtx(context.Background(), Code)
+}
+
+// RedeemCodeCtx is RedeemCode with a context, cancelling ctx aborts the request
+func (s *Session) RedeemCodeCtx(ctx context.Context, Code string) (*RedeemCodeResponse, error) {
+	b, err := s.PostFormCtx(ctx, RedeemCodePath, "code="+Code)
 	if err != nil {
 		return nil, err
 	}
 	r := &RedeemCodeResponse{}
-	err = json.Unmarshal(b, &r)
+	err = s.decode(RedeemCodePath, b, r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Success {
+		msg := r.Err
+		if msg == "" {
+			msg = r.Message
+		}
+		return nil, unsuccessful(RedeemCodePath, msg)
+	}
 	return r, nil
 }
 
 // GetCoinflipHistory retreeves the previous coinflip games
 func (s *Session) GetCoinflipHistory() (*CoinflipHistory, error) {
-	resp, err := s.AllInOneHTTP(false, "GET", HistoryAPIURL+"coinflip", nil)
+	return s.GetCoinflipHistoryCtx(context.Background())
+}
+
+// GetCoinflipHistoryCtx is GetCoinflipHistory with a context, cancelling ctx aborts the request
+func (s *Session) GetCoinflipHistoryCtx(ctx context.Context) (*CoinflipHistory, error) {
+	resp, err := s.AllInOneHTTPCtx(ctx, false, "GET", HistoryAPIPath+"coinflip", nil)
 	if err != nil {
 		return nil, err
 	}
 	r := &CoinflipHistory{}
-	err = json.Unmarshal(resp, &r)
+	err = s.decode(HistoryAPIPath+"coinflip", resp, r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Success {
+		return nil, unsuccessful(HistoryAPIPath+"coinflip", "")
+	}
 	return r, nil
 }
 
 // GetJackpotHistory retreeves the history of previous jackpot games
 // Room is either low or high depending on which room you want
 func (s *Session) GetJackpotHistory(Room string) (*JackpotHistory, error) {
+	return s.GetJackpotHistoryCtx(context.Background(), Room)
+}
+
+// GetJackpotHistoryCtx is GetJackpotHistory with a context, cancelling ctx aborts the request
+func (s *Session) GetJackpotHistoryCtx(ctx context.Context, Room string) (*JackpotHistory, error) {
 	if Room != "low" && Room != "high" {
 		return nil, errors.New("invalid room provided, must be high or low")
 	}
-	resp, err := s.AllInOneHTTP(false, "GET", HistoryAPIURL+"jackpot?room="+Room, nil)
+	resp, err := s.AllInOneHTTPCtx(ctx, false, "GET", HistoryAPIPath+"jackpot?room="+Room, nil)
 	if err != nil {
 		return nil, err
 	}
 	r := &JackpotHistory{}
-	err = json.Unmarshal(resp, &r)
+	err = s.decode(HistoryAPIPath+"jackpot", resp, r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Success {
+		return nil, unsuccessful(HistoryAPIPath+"jackpot", "")
+	}
 	return r, nil
 }
 
 // GetCrashGame gets a coinflip game by ID
 // ID is the ID of the coinflip game
 func (s *Session) GetCrashGame(ID string) (*CrashGame, error) {
-	resp, err := s.AllInOneHTTP(false, "GET", CrashGameURL+ID, nil)
+	return s.GetCrashGameCtx(context.Background(), ID)
+}
+
+// GetCrashGameCtx is GetCrashGame with a context, cancelling ctx aborts the request
+func (s *Session) GetCrashGameCtx(ctx context.Context, ID string) (*CrashGame, error) {
+	resp, err := s.AllInOneHTTPCtx(ctx, false, "GET", CrashGamePath+ID, nil)
 	if err != nil {
 		return nil, err
 	}
 	r := &CrashGame{}
-	err = json.Unmarshal(resp, &r)
+	err = s.decode(CrashGamePath, resp, r)
 	if err != nil {
 		return nil, err
 	}
+	if !r.Success {
+		return nil, unsuccessful(CrashGamePath+ID, "")
+	}
 	return r, nil
 }
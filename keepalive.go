@@ -0,0 +1,156 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultPingInterval is how often NewSession has the socket send a ping
+	DefaultPingInterval = 25 * time.Second
+	// DefaultReadTimeout is how long NewSession lets the socket go without any frame or pong before it's treated as dead
+	DefaultReadTimeout = 60 * time.Second
+)
+
+// StaleFeedError is why the socket was dropped when a room in Session.StaleTimeout went quiet for too long, it's the Err of the Disconnected event
+type StaleFeedError struct {
+	// Room is the room that went quiet, for example "crash"
+	Room string
+	// Silence is how long it had been since the last payload from Room
+	Silence time.Duration
+}
+
+// Error formats the error like "no payload from crash for 30s"
+func (e *StaleFeedError) Error() string {
+	return fmt.Sprintf("no payload from %s for %s", e.Room, e.Silence.Round(time.Millisecond))
+}
+
+// WithKeepalive sets how often the socket is pinged and how long it can go without a frame or pong before it's reconnected, zero turns either off
+func WithKeepalive(pingInterval, readTimeout time.Duration) Option {
+	return func(s *Session) error {
+		if pingInterval < 0 || readTimeout < 0 {
+			return errors.New("keepalive durations can't be negative")
+		}
+		if pingInterval > 0 && readTimeout > 0 && readTimeout <= pingInterval {
+			return errors.New("read timeout has to be longer than the ping interval")
+		}
+		s.PingInterval = pingInterval
+		s.ReadTimeout = readTimeout
+		return nil
+	}
+}
+
+// WithStaleTimeout reconnects the socket when room hasn't sent a payload for d while it's joined, for example WithStaleTimeout("crash", 30*time.Second)
+func WithStaleTimeout(room string, d time.Duration) Option {
+	return func(s *Session) error {
+		if err := checkRooms([]string{room}); err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("stale timeout has to be positive")
+		}
+		if s.StaleTimeout == nil {
+			s.StaleTimeout = make(map[string]time.Duration)
+		}
+		s.StaleTimeout[room] = d
+		return nil
+	}
+}
+
+// touch marks room as having just sent a payload
+func (s *Session) touch(room string) {
+	if len(s.StaleTimeout) == 0 {
+		return
+	}
+	s.seenMu.Lock()
+	if s.seen == nil {
+		s.seen = make(map[string]time.Time)
+	}
+	s.seen[room] = time.Now()
+	s.seenMu.Unlock()
+}
+
+// resetSeen starts the stale window of every room over, it's called for every new connection
+func (s *Session) resetSeen() {
+	s.seenMu.Lock()
+	s.seen = nil
+	s.seenMu.Unlock()
+}
+
+// staleRoom returns the first joined room that's been quiet for longer than its stale timeout
+// A room that hasn't sent anything yet counts from the first time it's checked so rooms joined late get a full window
+func (s *Session) staleRoom(now time.Time) *StaleFeedError {
+	joined := s.JoinedRooms()
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[string]time.Time)
+	}
+	for _, room := range joined {
+		window, ok := s.StaleTimeout[room]
+		if !ok {
+			continue
+		}
+		last, ok := s.seen[room]
+		if !ok {
+			s.seen[room] = now
+			continue
+		}
+		if silence := now.Sub(last); silence > window {
+			return &StaleFeedError{Room: room, Silence: silence}
+		}
+	}
+	return nil
+}
+
+// staleCheckInterval is how often the watchdog looks at the rooms, a quarter of the shortest window so a stale room is caught at most 25% late
+func (s *Session) staleCheckInterval() time.Duration {
+	var min time.Duration
+	for _, d := range s.StaleTimeout {
+		if d > 0 && (min == 0 || d < min) {
+			min = d
+		}
+	}
+	return min / 4
+}
+
+// keepalive runs next to the read loop of c until stop is closed, it closes c when ctx is done, pings it every PingInterval and closes it when a room goes stale
+// The reason c was closed for is sent on reason so the read loop can report it instead of the read error
+func (s *Session) keepalive(ctx context.Context, c *websocket.Conn, stop <-chan struct{}, reason chan<- error) {
+	var ping, watch <-chan time.Time
+	if s.PingInterval > 0 {
+		t := time.NewTicker(s.PingInterval)
+		defer t.Stop()
+		ping = t.C
+	}
+	if d := s.staleCheckInterval(); d > 0 {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		watch = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			c.Close()
+			return
+		case <-stop:
+			return
+		case <-ping:
+			// WriteControl is safe to call next to other writes so this doesn't need SocketMutex
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.PingInterval)); err != nil {
+				s.logger().Debug("socket ping failed", "error", err)
+			}
+		case now := <-watch:
+			if err := s.staleRoom(now); err != nil {
+				s.logger().Warn("socket feed is stale", "room", err.Room, "silence", err.Silence)
+				reason <- err
+				c.Close()
+				return
+			}
+		}
+	}
+}
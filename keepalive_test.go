@@ -0,0 +1,85 @@
+package wrapper_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+func TestStaleFeedReconnects(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash", "chat"), wrapper.WithStaleTimeout("crash", 200*time.Millisecond))
+	disconnected := make(chan error, 1)
+	s.AddHandler(func(_ *wrapper.Session, d *wrapper.Disconnected) {
+		select {
+		case disconnected <- d.Err:
+		default:
+		}
+	})
+	resumed := make(chan struct{}, 1)
+	s.AddHandler(func(_ *wrapper.Session, _ *wrapper.Resumed) {
+		notify(resumed)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	// chat keeps talking but it isn't watched, crash stays quiet
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(20 * time.Millisecond):
+				srv.Emit("chat", "message", map[string]interface{}{})
+			}
+		}
+	}()
+	start := time.Now()
+	var err error
+	select {
+	case err = <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the quiet crash feed never dropped the socket")
+	}
+	var stale *wrapper.StaleFeedError
+	if !errors.As(err, &stale) || stale.Room != "crash" {
+		t.Fatalf("Disconnected.Err = %v, want a *StaleFeedError for crash", err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("socket dropped after %s, before the stale timeout", d)
+	}
+	waitFor(t, resumed, "the socket to resume")
+}
+
+func TestStaleFeedKeptAlive(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash"), wrapper.WithStaleTimeout("crash", 200*time.Millisecond))
+	disconnected := make(chan error, 1)
+	s.AddHandler(func(_ *wrapper.Session, d *wrapper.Disconnected) {
+		select {
+		case disconnected <- d.Err:
+		default:
+		}
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	for i := 0; i < 30; i++ {
+		srv.Emit("crash", "tick", i*100)
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case err := <-disconnected:
+		t.Fatalf("socket dropped while crash was sending ticks: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,59 @@
+package wrapper
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is what a Session writes its logs to, args are key value pairs like "room", "crash", "attempt", 2
+// A *slog.Logger satisfies this interface so it can be set on Session.Logger directly
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// logger returns the logger the session should use, when Logger isn't set and Log is true warnings and errors go to the standard log package, otherwise nothing is logged
+func (s *Session) logger() Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	if s.Log {
+		return stdLogger{}
+	}
+	return nopLogger{}
+}
+
+// nopLogger drops everything, it's used when logging is off
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// stdLogger writes warnings and errors to the standard log package as "LEVEL msg key=value key=value"
+// Debug and Info are dropped so Session.Log keeps logging only what went wrong, set Session.Logger to see everything
+type stdLogger struct{}
+
+func (stdLogger) Debug(string, ...interface{})          {}
+func (stdLogger) Info(string, ...interface{})           {}
+func (stdLogger) Warn(msg string, args ...interface{})  { logStd("WARN", msg, args) }
+func (stdLogger) Error(msg string, args ...interface{}) { logStd("ERROR", msg, args) }
+
+func logStd(level, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&b, " !BADKEY=%v", args[i])
+		}
+	}
+	log.Println(b.String())
+}
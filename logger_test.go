@@ -0,0 +1,58 @@
+package wrapper_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// syncBuffer is a bytes.Buffer the log package and the test can use at the same time
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestLogOnlyErrors(t *testing.T) {
+	var out syncBuffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&out)
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash"))
+	s.Log = true
+	ticks := make(chan struct{}, 3)
+	s.AddHandler(func(_ *wrapper.Session, _ *wrapper.CrashTick) {
+		notify(ticks)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	for i := 1; i <= 3; i++ {
+		srv.Emit("crash", "tick", i*100)
+		waitFor(t, ticks, "a crash tick")
+	}
+	s.Close()
+	for _, level := range []string{"DEBUG", "INFO"} {
+		if strings.Contains(out.String(), level) {
+			t.Fatalf("Log printed %s lines:\n%s", level, out.String())
+		}
+	}
+}
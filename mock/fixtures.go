@@ -0,0 +1,42 @@
+package mock
+
+// The fixtures below are shaped like the real rustchance responses the wrapper structs were written against
+
+// AccountLeaderboardJSON is served on /api/account/leaderboard
+const AccountLeaderboardJSON = `{"ranked":true,"tickets":120,"position":4}`
+
+// TicketsLeaderboardJSON is served on /api/bonuses
+const TicketsLeaderboardJSON = `{"success":true,"result":{"leaderboard":[{"id":1,"username":"mock-one","avatar":"https://example.com/1.png","tickets":500},{"id":2,"username":"mock-two","avatar":"https://example.com/2.png","tickets":250}],"rewards":[2500,1000]}}`
+
+// AccountEarningsJSON is served on /api/account/stats/all
+const AccountEarningsJSON = `{"success":true,"result":{"wagered":10000,"won":9500}}`
+
+// ProfileHTML is served on /profile, the wrapper pulls window.userData out of it
+const ProfileHTML = `<!DOCTYPE html>
+<html>
+<head>
+<script>
+window.userData={auth:true,id:1,steamid:"76561198000000000",name:"mock",avatar:"https://example.com/avatar.png",tradelink:"",rank:0,experience:1200,points:5000,frozen:false}
+</script>
+</head>
+<body></body>
+</html>
+`
+
+// FaucetJSON is served on /api/account/faucet
+const FaucetJSON = `{"success":true,"result":3}`
+
+// RedeemCodeJSON is served on /api/affiliates/redeem
+const RedeemCodeJSON = `{"success":true,"message":"code redeemed"}`
+
+// SerialJSON is served on /api/serial/, %s is replaced with the serial number that was asked for
+const SerialJSON = `{"success":true,"result":{"createdAt":"2021-07-21T00:00:00.000Z","game":"crash","gameID":"1","hashedApiKey":"mock","hidden":false,"number":%s,"rawMessage":"mock","seed":"mock-seed","signature":"mock-signature"}}`
+
+// CoinflipHistoryJSON is served on /api/history/coinflip
+const CoinflipHistoryJSON = `{"success":true,"result":[{"blue_side":{"avatar":"https://example.com/1.png","id":1,"name":"mock-one"},"diff":0,"hash":"mock-hash","id":1,"items":[[1,100]],"red_side":{"avatar":"https://example.com/2.png","id":2,"name":"mock-two"},"secret":"mock-secret","seed":"mock-seed","serialNumber":1,"ticketNumber":42,"time":1626825600,"value":200,"winner":"blue"}]}`
+
+// JackpotHistoryJSON is served on /api/history/jackpot
+const JackpotHistoryJSON = `{"success":true,"result":[{"hash":"mock-hash","id":1,"items":[[1,100]],"secret":"mock-secret","seed":"mock-seed","serialNumber":1,"ticketNumber":42,"time":1626825600,"value":200,"winner":{"avatar":"https://example.com/1.png","chance":"50.00","id":1,"name":"mock-one"}}]}`
+
+// CrashGameJSON is served on /api/crash/game/, %s is replaced with the game id that was asked for
+const CrashGameJSON = `{"success":true,"result":{"bets":[{"amount":100,"cashedOut":true,"cashoutAt":1.5,"createdAt":"2021-07-21T00:00:00Z","gameID":%[1]s,"id":1,"profile":{"avatar":"https://example.com/1.png","nickname":"mock-one","steamID":"76561198000000000"},"updatedAt":"2021-07-21T00:00:10Z","userID":1}],"game":{"crashedAt":2.31,"createdAt":"2021-07-21T00:00:00Z","id":%[1]s,"seed":1,"state":3,"updatedAt":"2021-07-21T00:00:20Z"}}}`
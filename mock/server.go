@@ -0,0 +1,419 @@
+// Package mock is an in-process fake of rustchance.com for running the wrapper and bots built on it without a network
+// It serves the JSON shapes of the http api and a /feed websocket that accepts join_rooms and leave_rooms and emits scripted events
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	wrapper "github.com/post04/rustchance-api-wrapper"
+)
+
+// Server is a fake rustchance.com, point a Session at it with wrapper.WithEndpoints(srv.Endpoints())
+type Server struct {
+	// URL is the http origin of the server, like "http://127.0.0.1:41234"
+	URL string
+	// OnPayload is called with every payload a client writes to the feed after it's been handled by the server and a running Simulator, set it before clients connect
+	OnPayload func(c *Client, p wrapper.Payload)
+
+	http     *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu        sync.Mutex
+	responses map[string]response
+	onJoin    []wrapper.Payload
+	clients   map[*Client]struct{}
+	received  []wrapper.Payload
+	joined    chan struct{}
+	sim       *Simulator
+}
+
+// response is an http response set with SetResponse
+type response struct {
+	status int
+	body   string
+}
+
+// NewServer starts a server on a random local port serving the default fixtures
+func NewServer() *Server {
+	s := &Server{
+		responses: make(map[string]response),
+		clients:   make(map[*Client]struct{}),
+		joined:    make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", s.serveFeed)
+	mux.HandleFunc("/", s.serveHTTP)
+	s.http = httptest.NewServer(mux)
+	s.URL = s.http.URL
+	return s
+}
+
+// Endpoints returns the endpoints of this server for wrapper.WithEndpoints
+func (s *Server) Endpoints() wrapper.Endpoints {
+	return wrapper.Endpoints{
+		HTTP:   s.URL,
+		Socket: "ws" + strings.TrimPrefix(s.URL, "http") + "/feed",
+	}
+}
+
+// Close stops a running Simulator, disconnects every client and shuts the server down
+func (s *Server) Close() {
+	s.mu.Lock()
+	sim := s.sim
+	s.mu.Unlock()
+	if sim != nil {
+		sim.Stop()
+	}
+	s.DropClients()
+	s.http.Close()
+}
+
+// SetResponse overrides what path answers with, body is sent as is when it's a string or []byte and marshalled to json otherwise
+// Path is matched exactly without the query, for example "/api/history/jackpot"
+func (s *Server) SetResponse(path string, status int, body interface{}) error {
+	var b string
+	switch v := body.(type) {
+	case string:
+		b = v
+	case []byte:
+		b = string(v)
+	default:
+		j, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b = string(j)
+	}
+	s.mu.Lock()
+	s.responses[path] = response{status: status, body: b}
+	s.mu.Unlock()
+	return nil
+}
+
+// ClearResponses goes back to serving the default fixtures
+func (s *Server) ClearResponses() {
+	s.mu.Lock()
+	s.responses = make(map[string]response)
+	s.mu.Unlock()
+}
+
+// OnJoin adds payloads every client gets right after it sends join_rooms, a payload is only sent when the client joined its room
+// Payloads in the "user" room are always sent, that's how rustchance sends account events like user_set_points
+func (s *Server) OnJoin(payloads ...wrapper.Payload) {
+	s.mu.Lock()
+	s.onJoin = append(s.onJoin, payloads...)
+	s.mu.Unlock()
+}
+
+// Emit sends a payload to every client that joined room
+func (s *Server) Emit(room, t string, data interface{}) error {
+	b, err := json.Marshal(wrapper.Payload{Room: room, Type: t, Data: data})
+	if err != nil {
+		return err
+	}
+	for _, c := range s.Clients() {
+		if c.InRoom(room) {
+			c.WriteRaw(b)
+		}
+	}
+	return nil
+}
+
+// EmitRaw sends a raw message to every client no matter what rooms it joined, use it for malformed or multi payload messages
+func (s *Server) EmitRaw(message []byte) {
+	for _, c := range s.Clients() {
+		c.WriteRaw(message)
+	}
+}
+
+// Clients returns the clients connected to the feed right now
+func (s *Server) Clients() []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Client, 0, len(s.clients))
+	for c := range s.clients {
+		out = append(out, c)
+	}
+	return out
+}
+
+// DropClients closes every feed connection, it's how a test makes a session reconnect
+func (s *Server) DropClients() {
+	for _, c := range s.Clients() {
+		c.Close()
+	}
+}
+
+// Received returns every payload clients have written to the feed so far
+func (s *Server) Received() []wrapper.Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]wrapper.Payload(nil), s.received...)
+}
+
+// WaitForJoin blocks until n clients have sent join_rooms since the server started or ctx is done
+func (s *Server) WaitForJoin(ctx context.Context, n int) error {
+	for {
+		s.mu.Lock()
+		joins := 0
+		for c := range s.clients {
+			c.mu.Lock()
+			if c.joined {
+				joins++
+			}
+			c.mu.Unlock()
+		}
+		ch := s.joined
+		s.mu.Unlock()
+		if joins >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+	}
+}
+
+// serveHTTP answers the api endpoints with an override from SetResponse or the default fixture
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	res, ok := s.responses[r.URL.Path]
+	s.mu.Unlock()
+	if ok {
+		writeBody(w, res.status, res.body)
+		return
+	}
+	path := r.URL.Path
+	switch {
+	case path == wrapper.AccountLeaderboardPath:
+		s.authed(w, r, http.MethodGet, AccountLeaderboardJSON)
+	case path == wrapper.TicketsLeaderboardPath:
+		s.public(w, r, http.MethodGet, TicketsLeaderboardJSON)
+	case path == wrapper.AccountEarningsPath:
+		s.authed(w, r, http.MethodGet, AccountEarningsJSON)
+	case path == wrapper.AccountProfilePath:
+		s.authed(w, r, http.MethodGet, ProfileHTML)
+	case path == wrapper.FaucetClaimPath:
+		s.authed(w, r, http.MethodPost, FaucetJSON)
+	case path == wrapper.RedeemCodePath:
+		s.authed(w, r, http.MethodPost, RedeemCodeJSON)
+	case path == wrapper.HistoryAPIPath+"coinflip":
+		s.public(w, r, http.MethodGet, CoinflipHistoryJSON)
+	case path == wrapper.HistoryAPIPath+"jackpot":
+		if room := r.URL.Query().Get("room"); room != "low" && room != "high" {
+			writeBody(w, http.StatusBadRequest, `{"success":false,"error":"invalid room"}`)
+			return
+		}
+		s.public(w, r, http.MethodGet, JackpotHistoryJSON)
+	case strings.HasPrefix(path, wrapper.ProvefairSerialPath):
+		n := strings.TrimPrefix(path, wrapper.ProvefairSerialPath)
+		if !isNumber(n) {
+			writeBody(w, http.StatusOK, `{"success":false,"error":"invalid serial"}`)
+			return
+		}
+		s.public(w, r, http.MethodGet, fmt.Sprintf(SerialJSON, n))
+	case strings.HasPrefix(path, wrapper.CrashGamePath):
+		id := strings.TrimPrefix(path, wrapper.CrashGamePath)
+		if !isNumber(id) {
+			writeBody(w, http.StatusOK, `{"success":false,"error":"game not found"}`)
+			return
+		}
+		s.public(w, r, http.MethodGet, fmt.Sprintf(CrashGameJSON, id))
+	default:
+		writeBody(w, http.StatusNotFound, `{"success":false,"error":"not found"}`)
+	}
+}
+
+// public answers body when the method matches
+func (s *Server) public(w http.ResponseWriter, r *http.Request, method, body string) {
+	if r.Method != method {
+		writeBody(w, http.StatusMethodNotAllowed, `{"success":false,"error":"method not allowed"}`)
+		return
+	}
+	writeBody(w, http.StatusOK, body)
+}
+
+// authed answers body when the method matches and the request carries a token cookie, like rustchance it's 401 otherwise
+func (s *Server) authed(w http.ResponseWriter, r *http.Request, method, body string) {
+	if c, err := r.Cookie("token"); err != nil || c.Value == "" {
+		writeBody(w, http.StatusUnauthorized, `{"success":false,"error":"not logged in"}`)
+		return
+	}
+	s.public(w, r, method, body)
+}
+
+func writeBody(w http.ResponseWriter, status int, body string) {
+	if strings.HasPrefix(body, "<") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// serveFeed upgrades the connection and reads payloads from the client until it disconnects
+func (s *Server) serveFeed(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &Client{conn: conn, rooms: make(map[string]bool)}
+	if cookie, err := r.Cookie("token"); err == nil {
+		c.Token = cookie.Value
+	}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var p wrapper.Payload
+		if err := json.Unmarshal(message, &p); err != nil {
+			continue
+		}
+		s.handlePayload(c, p)
+	}
+}
+
+// handlePayload records p and takes care of the join_rooms and leave_rooms control payloads
+func (s *Server) handlePayload(c *Client, p wrapper.Payload) {
+	s.mu.Lock()
+	s.received = append(s.received, p)
+	sim := s.sim
+	s.mu.Unlock()
+	if p.Room == "control" && p.Type == "join_rooms" {
+		c.mu.Lock()
+		for _, room := range stringList(p.Data) {
+			c.rooms[room] = true
+		}
+		c.joined = true
+		c.mu.Unlock()
+		s.mu.Lock()
+		onJoin := append([]wrapper.Payload(nil), s.onJoin...)
+		close(s.joined)
+		s.joined = make(chan struct{})
+		s.mu.Unlock()
+		for _, e := range onJoin {
+			if e.Room == "user" || c.InRoom(e.Room) {
+				c.Send(e.Room, e.Type, e.Data)
+			}
+		}
+	}
+	if p.Room == "control" && p.Type == "leave_rooms" {
+		c.mu.Lock()
+		for _, room := range stringList(p.Data) {
+			delete(c.rooms, room)
+		}
+		c.mu.Unlock()
+	}
+	if sim != nil {
+		sim.handle(c, p)
+	}
+	if s.OnPayload != nil {
+		s.OnPayload(c, p)
+	}
+}
+
+// stringList turns the data of a join_rooms payload into a list of rooms
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Client is a single feed connection
+type Client struct {
+	// Token is the token cookie the client connected with, it's blank for unauthorized clients
+	Token string
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	rooms   map[string]bool
+	joined  bool
+	closed  bool
+}
+
+// Send writes a payload to this client
+func (c *Client) Send(room, t string, data interface{}) error {
+	b, err := json.Marshal(wrapper.Payload{Room: room, Type: t, Data: data})
+	if err != nil {
+		return err
+	}
+	return c.WriteRaw(b)
+}
+
+// WriteRaw writes a raw message to this client
+func (c *Client) WriteRaw(message []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return errors.New("client is closed")
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// InRoom says whether the client joined room
+func (c *Client) InRoom(room string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rooms[room]
+}
+
+// Rooms returns the rooms the client joined
+func (c *Client) Rooms() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		out = append(out, room)
+	}
+	return out
+}
+
+// Close drops the connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return c.conn.Close()
+}
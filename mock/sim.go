@@ -0,0 +1,829 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+)
+
+// The simulator takes these payloads from clients, the roulette one is what Session.BetRoulette sends
+// crash join_game {"amount":100,"cashoutAt":2} bets on the crash round that's taking bets, cashoutAt is optional and cashes out automatically
+// crash cashout with no data cashes out of the running round at the current multiplier
+// roulette join_game {"amount":100,"color":0,"id":1} bets on the current roulette round, color is 0 for blue, 1 for yellow and 2 for red
+// coinflip create_game {"value":100,"side":"red"} opens a coinflip lobby, coinflip join_game {"id":1} joins one
+
+// Errors passed to Simulator.OnReject when a client payload can't be honored
+var (
+	// ErrNotLoggedIn is a bet from a client that connected without a token
+	ErrNotLoggedIn = errors.New("not logged in")
+	// ErrInvalidBet is a bet with a bad amount, color or side
+	ErrInvalidBet = errors.New("invalid bet")
+	// ErrInsufficientBalance is a bet bigger than the balance of the client
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrBettingClosed is a bet on a round that isn't taking bets or a cashout when there's nothing to cash out
+	ErrBettingClosed = errors.New("betting is closed")
+	// ErrUnknownGame is a bet on a round or lobby that doesn't exist
+	ErrUnknownGame = errors.New("unknown game")
+)
+
+// States of a crash round, the ended state matches CrashGameJSON
+const (
+	CrashStateBetting = 1
+	CrashStateRunning = 2
+	CrashStateEnded   = 3
+)
+
+// Roulette colors as BetRoulette takes them
+const (
+	RouletteBlue   = 0
+	RouletteYellow = 1
+	RouletteRed    = 2
+)
+
+// RoulettePayout is what a winning roulette bet is multiplied by for each color
+var RoulettePayout = [3]int{RouletteBlue: 2, RouletteYellow: 14, RouletteRed: 2}
+
+// crashTickStep is how much game time in milliseconds every crash_tick moves the round forward, no matter how often ticks are sent
+const crashTickStep = 100
+
+// CrashMultiplier is the multiplier of a crash round elapsed milliseconds in, crash_tick sends the elapsed milliseconds
+func CrashMultiplier(elapsed int) float64 {
+	return math.Floor(100*math.Exp(0.00006*float64(elapsed))) / 100
+}
+
+// Simulator runs crash, roulette and coinflip games on a Server's feed and keeps a balance for every token
+// NewSimulator fills in timings that suit tests, change the fields before Start
+type Simulator struct {
+	// CrashBetting is how long a crash round takes bets between crash_new and crash_start
+	CrashBetting time.Duration
+	// CrashTick is how often crash_tick is sent while a round runs, every tick moves the round 100ms of game time forward so a short tick makes rounds fast
+	CrashTick time.Duration
+	// CrashPause is the wait between crash_end and the next crash_new
+	CrashPause time.Duration
+	// RouletteRound is how long a roulette round takes bets before roulette_roll
+	RouletteRound time.Duration
+	// CoinflipTimer is how long a full coinflip lobby counts down before it flips
+	CoinflipTimer time.Duration
+	// CoinflipBotJoin makes a bot join lobbies opened by clients after this long, zero leaves them open until someone joins
+	CoinflipBotJoin time.Duration
+	// Balance is what every token starts with, in cents
+	Balance int
+	// Seed seeds the random crash points, rolls and flips when nothing is queued
+	Seed int64
+	// OnReject is called when a client payload can't be honored, err is one of the Err values of this package
+	OnReject func(c *Client, p wrapper.Payload, err error)
+
+	srv *Server
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	balances  map[string]int
+	users     map[string]int
+	nextUser  int
+	crashQ    []float64
+	rollQ     []int
+	flipQ     []string
+	crash     crashRound
+	crashHist []wrapper.CrashListDataHistory
+	roulette  rouletteRound
+	rollHist  [][]int
+	lobbies   map[int]*lobby
+	nextLobby int
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// crashRound is the current crash round
+type crashRound struct {
+	id         int
+	state      int
+	elapsed    int
+	crashPoint float64
+	timeStart  time.Time
+	bets       map[string]*crashBet
+}
+
+type crashBet struct {
+	user      int
+	amount    int
+	cashoutAt float64
+	cashed    bool
+}
+
+// rouletteRound is the roulette round taking bets
+type rouletteRound struct {
+	id       int
+	roundEnd time.Time
+	bets     []rouletteBet
+	stats    [3]int
+}
+
+type rouletteBet struct {
+	token  string
+	amount int
+	color  int
+}
+
+// rouletteBetData is the element type of RouletteList's black, green and red bets
+type rouletteBetData = struct {
+	ID     int            `json:"i"`
+	Player wrapper.Player `json:"p"`
+	Bet    int            `json:"a"`
+}
+
+// lobby is an open or running coinflip game, a side with a blank token is a bot
+type lobby struct {
+	id     int
+	owner  string
+	value  int
+	status string
+	sides  map[string]*lobbySide
+}
+
+type lobbySide struct {
+	token string
+	user  int
+}
+
+// NewSimulator returns a simulator for srv with timings that suit tests, call Start to run it
+func NewSimulator(srv *Server) *Simulator {
+	return &Simulator{
+		CrashBetting:  200 * time.Millisecond,
+		CrashTick:     5 * time.Millisecond,
+		CrashPause:    100 * time.Millisecond,
+		RouletteRound: 500 * time.Millisecond,
+		CoinflipTimer: 200 * time.Millisecond,
+		Balance:       10000,
+		Seed:          1,
+		srv:           srv,
+	}
+}
+
+// Start runs the games and starts honoring bets, it's a no-op if the simulator is already running
+func (sim *Simulator) Start() {
+	sim.mu.Lock()
+	if sim.done != nil {
+		sim.mu.Unlock()
+		return
+	}
+	sim.done = make(chan struct{})
+	sim.rand = rand.New(rand.NewSource(sim.Seed))
+	if sim.balances == nil {
+		sim.balances = make(map[string]int)
+		sim.users = make(map[string]int)
+	}
+	sim.lobbies = make(map[int]*lobby)
+	sim.roulette = rouletteRound{id: sim.roulette.id + 1, roundEnd: time.Now().Add(sim.RouletteRound)}
+	sim.mu.Unlock()
+	sim.srv.mu.Lock()
+	sim.srv.sim = sim
+	sim.srv.mu.Unlock()
+	sim.wg.Add(2)
+	go sim.crashLoop()
+	go sim.rouletteLoop()
+}
+
+// Stop stops the games and waits for them, open bets are dropped without a refund
+func (sim *Simulator) Stop() {
+	sim.srv.mu.Lock()
+	if sim.srv.sim == sim {
+		sim.srv.sim = nil
+	}
+	sim.srv.mu.Unlock()
+	sim.mu.Lock()
+	done := sim.done
+	sim.done = nil
+	sim.mu.Unlock()
+	if done == nil {
+		return
+	}
+	close(done)
+	sim.wg.Wait()
+}
+
+// QueueCrash sets the crash points of the next rounds, rounds past the queue crash at random
+func (sim *Simulator) QueueCrash(points ...float64) {
+	sim.mu.Lock()
+	sim.crashQ = append(sim.crashQ, points...)
+	sim.mu.Unlock()
+}
+
+// QueueRoll sets the winning colors of the next roulette rounds
+func (sim *Simulator) QueueRoll(colors ...int) {
+	sim.mu.Lock()
+	sim.rollQ = append(sim.rollQ, colors...)
+	sim.mu.Unlock()
+}
+
+// QueueFlip sets the winning sides, "red" or "blue", of the next coinflips
+func (sim *Simulator) QueueFlip(sides ...string) {
+	sim.mu.Lock()
+	sim.flipQ = append(sim.flipQ, sides...)
+	sim.mu.Unlock()
+}
+
+// BalanceOf returns the balance of token in cents
+func (sim *Simulator) BalanceOf(token string) int {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.balanceLocked(token)
+}
+
+// SetBalance sets the balance of token and sends it user_set_points
+func (sim *Simulator) SetBalance(token string, points int) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.balanceLocked(token)
+	sim.balances[token] = points
+	sim.sendPoints(token)
+}
+
+// OpenCoinflip opens a coinflip lobby owned by a bot on side and returns its id, clients can join it with coinflip join_game
+func (sim *Simulator) OpenCoinflip(value int, side string) (int, error) {
+	if value <= 0 || (side != "red" && side != "blue") {
+		return 0, ErrInvalidBet
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.lobbies == nil {
+		return 0, errors.New("simulator isn't running")
+	}
+	l := sim.openLobby("", value, side)
+	return l.id, nil
+}
+
+// handle is called by the server for every payload a client writes while the simulator runs
+func (sim *Simulator) handle(c *Client, p wrapper.Payload) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.done == nil {
+		return
+	}
+	var err error
+	switch p.Room + "_" + p.Type {
+	case "control_join_rooms":
+		sim.welcome(c)
+	case "crash_join_game":
+		err = sim.betCrash(c, p.Data)
+	case "crash_cashout":
+		err = sim.cashoutCrash(c)
+	case "roulette_join_game":
+		err = sim.betRoulette(c, p.Data)
+	case "coinflip_create_game":
+		err = sim.createLobby(c, p.Data)
+	case "coinflip_join_game":
+		err = sim.joinLobby(c, p.Data)
+	}
+	if err != nil && sim.OnReject != nil {
+		sim.OnReject(c, p, err)
+	}
+}
+
+// welcome sends a client that just joined its rooms the balance and the state of every game it's watching, like the list payloads rustchance sends on connect
+func (sim *Simulator) welcome(c *Client) {
+	if c.Token != "" {
+		c.Send("user", "set_points", sim.balanceLocked(c.Token))
+	}
+	if c.InRoom("crash") {
+		bets := make([]wrapper.CrashMultipleBetsData, 0, len(sim.crash.bets))
+		for token, b := range sim.crash.bets {
+			bets = append(bets, sim.crashBetData(token, b))
+		}
+		c.Send("crash", "list", wrapper.CrashListData{
+			Game: wrapper.Game{
+				Bets:      bets,
+				Elapsed:   sim.crash.elapsed,
+				ID:        sim.crash.id,
+				State:     sim.crash.state,
+				TimeStart: sim.crash.timeStart,
+				Timer:     int(sim.CrashBetting / time.Millisecond),
+			},
+			History:  sim.crashHist,
+			Settings: wrapper.CrashListDataSettings{MinValue: 1, MaxValue: sim.Balance * 100, MaxWin: sim.Balance * 1000},
+		})
+	}
+	if c.InRoom("roulette") {
+		c.Send("roulette", "list", sim.rouletteListData())
+	}
+	if c.InRoom("coinflip") {
+		games := make([]wrapper.CoinflipNewGameData, 0, len(sim.lobbies))
+		for _, l := range sim.lobbies {
+			games = append(games, sim.lobbyData(l))
+		}
+		c.Send("coinflip", "list", wrapper.CoinflipListData{Games: games})
+	}
+}
+
+// crashLoop runs crash rounds until Stop
+func (sim *Simulator) crashLoop() {
+	defer sim.wg.Done()
+	for {
+		sim.mu.Lock()
+		point := randomCrashPoint(sim.rand)
+		if len(sim.crashQ) > 0 {
+			point, sim.crashQ = sim.crashQ[0], sim.crashQ[1:]
+		}
+		sim.crash = crashRound{
+			id:         sim.crash.id + 1,
+			state:      CrashStateBetting,
+			crashPoint: point,
+			timeStart:  time.Now().Add(sim.CrashBetting).UTC(),
+			bets:       make(map[string]*crashBet),
+		}
+		sim.srv.Emit("crash", "new", wrapper.CrashNewData{
+			ID:        sim.crash.id,
+			State:     CrashStateBetting,
+			TimeStart: sim.crash.timeStart.Format(time.RFC3339Nano),
+			Timer:     int(sim.CrashBetting / time.Millisecond),
+		})
+		sim.mu.Unlock()
+		if !sim.sleep(sim.CrashBetting) {
+			return
+		}
+
+		sim.mu.Lock()
+		sim.crash.state = CrashStateRunning
+		sim.crash.timeStart = time.Now().UTC()
+		sim.srv.Emit("crash", "start", wrapper.CrashStartData{
+			State:     CrashStateRunning,
+			TimeStart: sim.crash.timeStart.Format(time.RFC3339Nano),
+		})
+		sim.mu.Unlock()
+		for {
+			if !sim.sleep(sim.CrashTick) {
+				return
+			}
+			sim.mu.Lock()
+			sim.crash.elapsed += crashTickStep
+			m := CrashMultiplier(sim.crash.elapsed)
+			if m >= sim.crash.crashPoint {
+				sim.endCrash()
+				sim.mu.Unlock()
+				break
+			}
+			sim.srv.Emit("crash", "tick", sim.crash.elapsed)
+			for token, b := range sim.crash.bets {
+				if !b.cashed && b.cashoutAt > 0 && m >= b.cashoutAt {
+					sim.cashout(token, b, b.cashoutAt)
+				}
+			}
+			sim.mu.Unlock()
+		}
+		if !sim.sleep(sim.CrashPause) {
+			return
+		}
+	}
+}
+
+// endCrash ends the running round at its crash point, bets that didn't cash out are lost
+func (sim *Simulator) endCrash() {
+	sim.crash.state = CrashStateEnded
+	sim.crashHist = append([]wrapper.CrashListDataHistory{{CrashPoint: sim.crash.crashPoint, ID: sim.crash.id}}, sim.crashHist...)
+	if len(sim.crashHist) > 20 {
+		sim.crashHist = sim.crashHist[:20]
+	}
+	sim.srv.Emit("crash", "end", wrapper.CrashEndData{
+		CrashPoint: sim.crash.crashPoint,
+		ID:         sim.crash.id,
+		State:      CrashStateEnded,
+		Timer:      int(sim.CrashPause / time.Millisecond),
+	})
+}
+
+// randomCrashPoint picks a crash point with a 1% house edge, a few rounds crash instantly like on the real site
+func randomCrashPoint(r *rand.Rand) float64 {
+	if r.Intn(33) == 0 {
+		return 1
+	}
+	point := math.Floor(100*0.99/(1-r.Float64())) / 100
+	return math.Max(1, math.Min(point, 1000))
+}
+
+func (sim *Simulator) betCrash(c *Client, data interface{}) error {
+	if c.Token == "" {
+		return ErrNotLoggedIn
+	}
+	var bet struct {
+		Amount    int     `json:"amount"`
+		CashoutAt float64 `json:"cashoutAt"`
+	}
+	if err := convert(data, &bet); err != nil || bet.Amount <= 0 || (bet.CashoutAt != 0 && bet.CashoutAt <= 1) {
+		return ErrInvalidBet
+	}
+	if sim.crash.state != CrashStateBetting || sim.crash.bets[c.Token] != nil {
+		return ErrBettingClosed
+	}
+	if err := sim.charge(c.Token, bet.Amount); err != nil {
+		return err
+	}
+	b := &crashBet{user: sim.userID(c.Token), amount: bet.Amount, cashoutAt: bet.CashoutAt}
+	sim.crash.bets[c.Token] = b
+	sim.srv.Emit("crash", "multiple_bets", []wrapper.CrashMultipleBetsData{sim.crashBetData(c.Token, b)})
+	return nil
+}
+
+func (sim *Simulator) cashoutCrash(c *Client) error {
+	if c.Token == "" {
+		return ErrNotLoggedIn
+	}
+	b := sim.crash.bets[c.Token]
+	if sim.crash.state != CrashStateRunning || b == nil || b.cashed {
+		return ErrBettingClosed
+	}
+	sim.cashout(c.Token, b, CrashMultiplier(sim.crash.elapsed))
+	return nil
+}
+
+// cashout pays a crash bet out at multiplier and sends crash_cashout
+func (sim *Simulator) cashout(token string, b *crashBet, at float64) {
+	b.cashed = true
+	amount := int(float64(b.amount) * at)
+	sim.credit(token, amount)
+	sim.srv.Emit("crash", "cashout", wrapper.CrashCashOutData{Amount: amount, CashoutAt: at, ID: b.user})
+}
+
+func (sim *Simulator) crashBetData(token string, b *crashBet) wrapper.CrashMultipleBetsData {
+	return wrapper.CrashMultipleBetsData{
+		Avatar: "https://example.com/avatar.png",
+		Bet:    b.amount,
+		ID:     sim.crash.id,
+		Name:   fmt.Sprintf("mock-%d", b.user),
+		UserID: b.user,
+	}
+}
+
+// rouletteLoop rolls a roulette round every RouletteRound until Stop
+func (sim *Simulator) rouletteLoop() {
+	defer sim.wg.Done()
+	for {
+		if !sim.sleep(sim.RouletteRound) {
+			return
+		}
+		sim.mu.Lock()
+		color := rouletteColor(sim.rand.Intn(15))
+		if len(sim.rollQ) > 0 {
+			color, sim.rollQ = sim.rollQ[0], sim.rollQ[1:]
+		}
+		r := &sim.roulette
+		if color >= 0 && color < len(r.stats) {
+			r.stats[color]++
+		}
+		for _, b := range r.bets {
+			if b.color == color {
+				sim.credit(b.token, b.amount*RoulettePayout[color])
+			}
+		}
+		game := r.id
+		sim.rollHist = append([][]int{{game, color}}, sim.rollHist...)
+		if len(sim.rollHist) > 20 {
+			sim.rollHist = sim.rollHist[:20]
+		}
+		sim.roulette = rouletteRound{id: game + 1, roundEnd: time.Now().Add(sim.RouletteRound), stats: r.stats}
+		sim.srv.Emit("roulette", "roll", map[string]interface{}{
+			"game":   game,
+			"number": color,
+			"newGame": wrapper.RouletteNewGame{
+				Black:    []interface{}{},
+				Green:    []interface{}{},
+				Red:      []interface{}{},
+				ID:       game + 1,
+				RoundEnd: int(sim.roulette.roundEnd.UnixNano() / int64(time.Millisecond)),
+				Timer:    int(sim.RouletteRound / time.Millisecond),
+			},
+			"statistics": sim.rouletteStats(),
+		})
+		sim.mu.Unlock()
+	}
+}
+
+// rouletteColor maps a slot of the 15 slot wheel to its color, one yellow slot and seven of blue and red
+func rouletteColor(slot int) int {
+	switch {
+	case slot == 0:
+		return RouletteYellow
+	case slot <= 7:
+		return RouletteBlue
+	default:
+		return RouletteRed
+	}
+}
+
+// rouletteListData is the data of the roulette_list sent to clients joining the room
+func (sim *Simulator) rouletteListData() interface{} {
+	var list wrapper.RouletteList
+	d := &list.Data
+	d.Current.ID = sim.roulette.id
+	d.Current.RoundEnd = int(sim.roulette.roundEnd.UnixNano() / int64(time.Millisecond))
+	d.Current.Timer = int(sim.RouletteRound / time.Millisecond)
+	d.Current.Blue = []rouletteBetData{}
+	d.Current.Yellow = []rouletteBetData{}
+	d.Current.Red = []rouletteBetData{}
+	for i, b := range sim.roulette.bets {
+		user := sim.userID(b.token)
+		bet := rouletteBetData{
+			ID:     i + 1,
+			Player: wrapper.Player{Avatar: "https://example.com/avatar.png", ID: user, Name: fmt.Sprintf("mock-%d", user), Bet: b.amount},
+			Bet:    b.amount,
+		}
+		switch b.color {
+		case RouletteBlue:
+			d.Current.Blue = append(d.Current.Blue, bet)
+		case RouletteYellow:
+			d.Current.Yellow = append(d.Current.Yellow, bet)
+		case RouletteRed:
+			d.Current.Red = append(d.Current.Red, bet)
+		}
+	}
+	d.Settings.MinValue = 1
+	d.Settings.MaxValue = sim.Balance * 100
+	d.Settings.GameTime = int(sim.RouletteRound / time.Millisecond)
+	d.Statistics.Blue = sim.roulette.stats[RouletteBlue]
+	d.Statistics.Gold = sim.roulette.stats[RouletteYellow]
+	d.Statistics.Red = sim.roulette.stats[RouletteRed]
+	d.History = sim.rollHist
+	if d.History == nil {
+		d.History = [][]int{}
+	}
+	return d
+}
+
+func (sim *Simulator) rouletteStats() map[string]int {
+	return map[string]int{
+		"blue": sim.roulette.stats[RouletteBlue],
+		"gold": sim.roulette.stats[RouletteYellow],
+		"red":  sim.roulette.stats[RouletteRed],
+	}
+}
+
+func (sim *Simulator) betRoulette(c *Client, data interface{}) error {
+	if c.Token == "" {
+		return ErrNotLoggedIn
+	}
+	var bet wrapper.EnterRouletteData
+	if err := convert(data, &bet); err != nil || bet.Amount <= 0 || bet.Color < 0 || bet.Color > 2 {
+		return ErrInvalidBet
+	}
+	if bet.ID != sim.roulette.id {
+		return ErrUnknownGame
+	}
+	if err := sim.charge(c.Token, bet.Amount); err != nil {
+		return err
+	}
+	sim.roulette.bets = append(sim.roulette.bets, rouletteBet{token: c.Token, amount: bet.Amount, color: bet.Color})
+	return nil
+}
+
+func (sim *Simulator) createLobby(c *Client, data interface{}) error {
+	if c.Token == "" {
+		return ErrNotLoggedIn
+	}
+	var game struct {
+		Value int    `json:"value"`
+		Side  string `json:"side"`
+	}
+	if err := convert(data, &game); err != nil || game.Value <= 0 || (game.Side != "red" && game.Side != "blue") {
+		return ErrInvalidBet
+	}
+	if err := sim.charge(c.Token, game.Value); err != nil {
+		return err
+	}
+	l := sim.openLobby(c.Token, game.Value, game.Side)
+	if sim.CoinflipBotJoin > 0 {
+		sim.after(sim.CoinflipBotJoin, func() {
+			if sim.lobbies[l.id] == l && l.status == "open" {
+				sim.fillLobby(l, "")
+			}
+		})
+	}
+	return nil
+}
+
+func (sim *Simulator) joinLobby(c *Client, data interface{}) error {
+	if c.Token == "" {
+		return ErrNotLoggedIn
+	}
+	var game struct {
+		ID int `json:"id"`
+	}
+	if err := convert(data, &game); err != nil {
+		return ErrInvalidBet
+	}
+	l := sim.lobbies[game.ID]
+	if l == nil {
+		return ErrUnknownGame
+	}
+	if l.status != "open" {
+		return ErrBettingClosed
+	}
+	if err := sim.charge(c.Token, l.value); err != nil {
+		return err
+	}
+	sim.fillLobby(l, c.Token)
+	return nil
+}
+
+// openLobby opens a lobby with its owner on side and sends coinflip_new_game
+func (sim *Simulator) openLobby(token string, value int, side string) *lobby {
+	sim.nextLobby++
+	l := &lobby{
+		id:     sim.nextLobby,
+		owner:  side,
+		value:  value,
+		status: "open",
+		sides:  map[string]*lobbySide{side: {token: token, user: sim.userID(token)}},
+	}
+	sim.lobbies[l.id] = l
+	sim.srv.Emit("coinflip", "new_game", sim.lobbyData(l))
+	return l
+}
+
+// fillLobby puts token on the free side of l, sends coinflip_update_game and flips once CoinflipTimer is up
+func (sim *Simulator) fillLobby(l *lobby, token string) {
+	free := "blue"
+	if l.owner == "blue" {
+		free = "red"
+	}
+	l.sides[free] = &lobbySide{token: token, user: sim.userID(token)}
+	l.status = "joined"
+	data := sim.lobbyData(l)
+	sim.srv.Emit("coinflip", "update_game", wrapper.CoinflipUpdateGameData{
+		BlueSide:     data.BlueSide,
+		Hash:         data.Hash,
+		ID:           l.id,
+		InitialValue: l.value,
+		Owner:        l.owner,
+		RedSide:      data.RedSide,
+		Status:       l.status,
+		TimeLeft:     int(sim.CoinflipTimer / time.Millisecond),
+		Timer:        int(sim.CoinflipTimer / time.Millisecond),
+		Value:        l.value * 2,
+	})
+	sim.after(sim.CoinflipTimer, func() { sim.flip(l) })
+}
+
+// flip settles a full lobby, pays the winner and removes the lobby
+func (sim *Simulator) flip(l *lobby) {
+	winner := "red"
+	if sim.rand.Intn(2) == 0 {
+		winner = "blue"
+	}
+	if len(sim.flipQ) > 0 {
+		winner, sim.flipQ = sim.flipQ[0], sim.flipQ[1:]
+	}
+	l.status = "finished"
+	if side := l.sides[winner]; side != nil {
+		sim.credit(side.token, l.value*2)
+	}
+	data := sim.lobbyData(l)
+	sim.srv.Emit("coinflip", "game_status", wrapper.CoinflipGameStatusData{
+		ID:           l.id,
+		RedSide:      data.RedSide,
+		BlueSide:     data.BlueSide,
+		Status:       l.status,
+		Secret:       fmt.Sprintf("mock-secret-%d", l.id),
+		Seed:         fmt.Sprintf("mock-seed-%d", l.id),
+		SerialNumber: l.id,
+		TicketNumber: sim.rand.Intn(100000),
+		WinnerSide:   winner,
+	})
+	delete(sim.lobbies, l.id)
+	sim.srv.Emit("coinflip", "delete_game", l.id)
+}
+
+func (sim *Simulator) lobbyData(l *lobby) wrapper.CoinflipNewGameData {
+	value := l.value
+	if len(l.sides) == 2 {
+		value *= 2
+	}
+	return wrapper.CoinflipNewGameData{
+		Hash:         fmt.Sprintf("mock-hash-%d", l.id),
+		ID:           l.id,
+		InitialValue: l.value,
+		Owner:        l.owner,
+		RedSide:      sideData(l.sides["red"]),
+		BlueSide:     sideData(l.sides["blue"]),
+		Status:       l.status,
+		Value:        value,
+	}
+}
+
+func sideData(side *lobbySide) wrapper.Side {
+	if side == nil {
+		return wrapper.Side{}
+	}
+	name := fmt.Sprintf("mock-%d", side.user)
+	if side.token == "" {
+		name = fmt.Sprintf("mock-bot-%d", side.user)
+	}
+	return wrapper.Side{
+		Avatar: "https://example.com/avatar.png",
+		ID:     side.user,
+		Name:   name,
+	}
+}
+
+// balanceLocked returns the balance of token, giving it the starting Balance the first time it's seen
+func (sim *Simulator) balanceLocked(token string) int {
+	if sim.balances == nil {
+		sim.balances = make(map[string]int)
+		sim.users = make(map[string]int)
+	}
+	b, ok := sim.balances[token]
+	if !ok {
+		b = sim.Balance
+		sim.balances[token] = b
+	}
+	return b
+}
+
+// charge takes amount from the balance of token
+func (sim *Simulator) charge(token string, amount int) error {
+	if sim.balanceLocked(token) < amount {
+		return ErrInsufficientBalance
+	}
+	sim.balances[token] -= amount
+	sim.sendPoints(token)
+	return nil
+}
+
+// credit adds amount to the balance of token, bots have a blank token and no balance
+func (sim *Simulator) credit(token string, amount int) {
+	if token == "" {
+		return
+	}
+	sim.balances[token] = sim.balanceLocked(token) + amount
+	sim.sendPoints(token)
+}
+
+// sendPoints sends user_set_points to every client logged in as token
+func (sim *Simulator) sendPoints(token string) {
+	for _, c := range sim.srv.Clients() {
+		if c.Token == token {
+			c.Send("user", "set_points", sim.balances[token])
+		}
+	}
+}
+
+// userID gives every token and every bot its own user id
+func (sim *Simulator) userID(token string) int {
+	if sim.users == nil {
+		sim.users = make(map[string]int)
+	}
+	if id, ok := sim.users[token]; ok && token != "" {
+		return id
+	}
+	sim.nextUser++
+	if token != "" {
+		sim.users[token] = sim.nextUser
+	}
+	return sim.nextUser
+}
+
+// sleep waits d and reports false if the simulator was stopped first
+func (sim *Simulator) sleep(d time.Duration) bool {
+	sim.mu.Lock()
+	done := sim.done
+	sim.mu.Unlock()
+	if done == nil {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-done:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// after calls fn with the lock held once d is up, unless the simulator is stopped first
+func (sim *Simulator) after(d time.Duration, fn func()) {
+	sim.wg.Add(1)
+	go func() {
+		defer sim.wg.Done()
+		if !sim.sleep(d) {
+			return
+		}
+		sim.mu.Lock()
+		defer sim.mu.Unlock()
+		if sim.done != nil {
+			fn()
+		}
+	}()
+}
+
+// convert decodes the generic json a payload's Data was read into onto v
+func convert(data, v interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
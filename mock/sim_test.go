@@ -0,0 +1,275 @@
+package mock_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// newSim starts a simulator on a new server with setup applied first, both are stopped when the test ends
+func newSim(t *testing.T, setup func(sim *mock.Simulator)) (*mock.Server, *mock.Simulator) {
+	t.Helper()
+	srv := mock.NewServer()
+	t.Cleanup(srv.Close)
+	sim := mock.NewSimulator(srv)
+	if setup != nil {
+		setup(sim)
+	}
+	sim.Start()
+	return srv, sim
+}
+
+// open connects a session logged in as token to srv, it's closed when the test ends
+func open(t *testing.T, srv *mock.Server, token string, rooms ...string) *wrapper.Session {
+	t.Helper()
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(srv.Endpoints()), wrapper.WithToken(token), wrapper.WithRooms(rooms...))
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// points tracks the last user_set_points a session got
+type points struct {
+	mu   sync.Mutex
+	last int
+	seen bool
+}
+
+func watchPoints(s *wrapper.Session) *points {
+	p := &points{}
+	s.AddHandler(func(_ *wrapper.Session, e *wrapper.UserSetPoints) {
+		p.mu.Lock()
+		p.last, p.seen = e.Data, true
+		p.mu.Unlock()
+	})
+	return p
+}
+
+// wait fails the test when the session hasn't been told its balance is want within 5 seconds
+func (p *points) wait(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		last, seen := p.last, p.seen
+		p.mu.Unlock()
+		if seen && last == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("last user_set_points is %d (seen %v), want %d", last, seen, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCrashCashout(t *testing.T) {
+	srv, sim := newSim(t, func(sim *mock.Simulator) {
+		sim.QueueCrash(2, 2, 2)
+	})
+	s := open(t, srv, "alice", "crash")
+	balance := watchPoints(s)
+	bet := make(chan int, 1)
+	s.AddHandler(func(s *wrapper.Session, e *wrapper.CrashNew) {
+		select {
+		case bet <- e.Data.ID:
+			s.Write(&wrapper.Payload{Room: "crash", Type: "join_game", Data: map[string]interface{}{"amount": 100, "cashoutAt": 1.5}})
+		default:
+		}
+	})
+	cashouts := make(chan wrapper.CrashCashOutData, 1)
+	s.AddHandler(func(_ *wrapper.Session, e *wrapper.CrashCashOut) {
+		// never block the read loop, Close waits for it when the test ends
+		select {
+		case cashouts <- e.Data:
+		default:
+		}
+	})
+	ended := make(chan wrapper.CrashEndData, 4)
+	s.AddHandler(func(_ *wrapper.Session, e *wrapper.CrashEnd) {
+		select {
+		case ended <- e.Data:
+		default:
+		}
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	balance.wait(t, 10000)
+	var round int
+	select {
+	case round = <-bet:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no crash_new")
+	}
+	select {
+	case c := <-cashouts:
+		if c.CashoutAt != 1.5 || c.Amount != 150 {
+			t.Fatalf("cashout = %+v, want 150 at 1.5", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the bet never cashed out")
+	}
+	for e := (wrapper.CrashEndData{}); e.ID != round; {
+		select {
+		case e = <-ended:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("round %d never ended", round)
+		}
+		if e.ID == round && e.CrashPoint != 2 {
+			t.Fatalf("round crashed at %v, want the queued 2", e.CrashPoint)
+		}
+	}
+	balance.wait(t, 10050)
+	if b := sim.BalanceOf("alice"); b != 10050 {
+		t.Fatalf("BalanceOf = %d, want 10050", b)
+	}
+}
+
+func TestRouletteWin(t *testing.T) {
+	srv, sim := newSim(t, func(sim *mock.Simulator) {
+		sim.RouletteRound = 200 * time.Millisecond
+		sim.QueueRoll(mock.RouletteBlue, mock.RouletteRed, mock.RouletteRed)
+	})
+	s := open(t, srv, "bob", "roulette")
+	balance := watchPoints(s)
+	rolls := make(chan wrapper.RouletteRollData, 8)
+	s.AddHandler(func(_ *wrapper.Session, e *wrapper.RouletteRoll) {
+		select {
+		case rolls <- e.Data:
+		default:
+		}
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// bet on the round after the first roll so the bet can't miss its round
+	var first wrapper.RouletteRollData
+	select {
+	case first = <-rolls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no roulette_roll")
+	}
+	if err := s.BetRoulette(300, first.NewGame.ID, mock.RouletteRed); err != nil {
+		t.Fatalf("BetRoulette: %v", err)
+	}
+	balance.wait(t, 9700)
+	for roll := first; roll.Game != first.NewGame.ID; {
+		select {
+		case roll = <-rolls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("round %d never rolled", first.NewGame.ID)
+		}
+		if roll.Game == first.NewGame.ID && roll.Color != mock.RouletteRed {
+			t.Fatalf("round rolled %d, want the queued red", roll.Color)
+		}
+	}
+	balance.wait(t, 9700+300*mock.RoulettePayout[mock.RouletteRed])
+	if b := sim.BalanceOf("bob"); b != 10300 {
+		t.Fatalf("BalanceOf = %d, want 10300", b)
+	}
+}
+
+func TestRejectedBets(t *testing.T) {
+	rejected := make(chan error, 4)
+	srv, sim := newSim(t, func(sim *mock.Simulator) {
+		// the first round, game 1, stays open for the whole test
+		sim.RouletteRound = time.Minute
+		sim.OnReject = func(_ *mock.Client, _ wrapper.Payload, err error) {
+			rejected <- err
+		}
+	})
+	s := open(t, srv, "carol", "roulette")
+	balance := watchPoints(s)
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	balance.wait(t, 10000)
+	sim.SetBalance("carol", 50)
+	balance.wait(t, 50)
+	cases := []struct {
+		amount, id int
+		want       error
+	}{
+		{amount: 100, id: 1, want: mock.ErrInsufficientBalance},
+		{amount: 10, id: 999, want: mock.ErrUnknownGame},
+	}
+	for _, c := range cases {
+		if err := s.BetRoulette(c.amount, c.id, mock.RouletteBlue); err != nil {
+			t.Fatalf("BetRoulette: %v", err)
+		}
+		select {
+		case err := <-rejected:
+			if err != c.want {
+				t.Fatalf("bet on game %d rejected with %v, want %v", c.id, err, c.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("bet on game %d wasn't rejected", c.id)
+		}
+	}
+	if b := sim.BalanceOf("carol"); b != 50 {
+		t.Fatalf("BalanceOf = %d after rejected bets, want 50", b)
+	}
+}
+
+func TestListsMatchWrapper(t *testing.T) {
+	srv, _ := newSim(t, func(sim *mock.Simulator) {
+		sim.RouletteRound = 200 * time.Millisecond
+	})
+	alice := open(t, srv, "alice", "roulette")
+	rolls := make(chan wrapper.RouletteRollData, 1)
+	alice.AddHandler(func(_ *wrapper.Session, e *wrapper.RouletteRoll) {
+		select {
+		case rolls <- e.Data:
+		default:
+		}
+	})
+	if err := alice.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// a rolled round and a bet on the next one so the list has history and bets in it
+	var roll wrapper.RouletteRollData
+	select {
+	case roll = <-rolls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no roulette_roll")
+	}
+	if err := alice.BetRoulette(100, roll.NewGame.ID, mock.RouletteRed); err != nil {
+		t.Fatalf("BetRoulette: %v", err)
+	}
+	watchPoints(alice).wait(t, 9900)
+
+	bob := open(t, srv, "bob", "crash", "roulette", "coinflip")
+	bob.Drift = wrapper.NewDriftDetector()
+	lists := make(chan struct{}, 3)
+	seen := func() {
+		select {
+		case lists <- struct{}{}:
+		default:
+		}
+	}
+	bob.AddHandler(func(_ *wrapper.Session, _ *wrapper.CrashList) { seen() })
+	bob.AddHandler(func(_ *wrapper.Session, _ *wrapper.RouletteList) { seen() })
+	bob.AddHandler(func(_ *wrapper.Session, _ *wrapper.CoinflipList) { seen() })
+	if err := bob.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-lists:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d of the crash, roulette and coinflip lists", i)
+		}
+	}
+	for _, r := range bob.Drift.Drifted() {
+		if strings.HasSuffix(r.Event, "_list") {
+			t.Errorf("%s doesn't match the wrapper: %+v", r.Event, r)
+		}
+	}
+}
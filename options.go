@@ -0,0 +1,246 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultHTTPTimeout is the overall timeout of the http client New creates, it covers dialing, the request and reading the body
+const DefaultHTTPTimeout = 30 * time.Second
+
+// Option configures a Session, options are passed to NewSession or New and applied in order before the session headers are built
+type Option func(*Session) error
+
+// KnownRooms are the socket rooms rustchance is known to have, WithRooms only accepts these and a session joins all of them by default
+var KnownRooms = []string{"chat", "crash", "shop", "coinflip", "jackpot", "jackpot-low", "supply-drops", "mines", "roulette"}
+
+// ChatRoomNames are the chat rooms rustchance has, WithChatRoom only accepts these
+var ChatRoomNames = []string{"en", "tr", "ru"}
+
+// WithToken sets the auth token of the session, without one the socket is unauthorized and account specific calls return ErrNoAuthToken
+func WithToken(token string) Option {
+	return func(s *Session) error {
+		if strings.ContainsAny(token, "\r\n;") {
+			return errors.New("token can't contain newlines or semicolons")
+		}
+		s.Auth = token
+		return nil
+	}
+}
+
+// WithRooms sets the socket rooms to join, every room has to be one of KnownRooms
+func WithRooms(rooms ...string) Option {
+	return func(s *Session) error {
+		if len(rooms) == 0 {
+			return errors.New("no rooms given")
+		}
+		if err := checkRooms(rooms); err != nil {
+			return err
+		}
+		joined := make([]string, 0, len(rooms))
+		for _, room := range rooms {
+			if !contains(joined, room) {
+				joined = append(joined, room)
+			}
+		}
+		s.Rooms = joined
+		return nil
+	}
+}
+
+// WithChatRoom sets the chat room that's joined after connecting, it has to be one of ChatRoomNames
+func WithChatRoom(room string) Option {
+	return func(s *Session) error {
+		if !contains(ChatRoomNames, room) {
+			return fmt.Errorf("unknown chat room %q, chat rooms are %s", room, strings.Join(ChatRoomNames, ", "))
+		}
+		s.Room = room
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the socket and of http requests, it replaces DefaultUserAgent
+func WithUserAgent(ua string) Option {
+	return func(s *Session) error {
+		if ua == "" {
+			return errors.New("user agent can't be blank")
+		}
+		if s.Headers == nil {
+			s.Headers = http.Header{}
+		}
+		s.Headers.Set("User-Agent", ua)
+		return nil
+	}
+}
+
+// WithHeaders adds headers to the socket handshake, they win over the defaults the session would set
+// Headers the websocket handshake sets itself like Sec-WebSocket-Key, Upgrade or Connection can't be set
+func WithHeaders(h http.Header) Option {
+	return func(s *Session) error {
+		if s.Headers == nil {
+			s.Headers = http.Header{}
+		}
+		for k, v := range h {
+			switch http.CanonicalHeaderKey(k) {
+			case "Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions":
+				return fmt.Errorf("header %s is set by the websocket handshake", k)
+			}
+			s.Headers[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+		return nil
+	}
+}
+
+// WithDialer makes the session open the socket with d, use it to set things like the handshake timeout or compression, see Session.Dialer for what's taken from the http client
+func WithDialer(d *websocket.Dialer) Option {
+	return func(s *Session) error {
+		if d == nil {
+			return errors.New("dialer can't be nil")
+		}
+		s.Dialer = d
+		return nil
+	}
+}
+
+// WithLogger sends the logs of the session to l, a *slog.Logger can be passed directly
+func WithLogger(l Logger) Option {
+	return func(s *Session) error {
+		if l == nil {
+			return errors.New("logger can't be nil")
+		}
+		s.Logger = l
+		return nil
+	}
+}
+
+// contains says whether list has v
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultEndpoints returns the endpoints of the real rustchance.com website
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		HTTP:   DefaultOrigin,
+		Socket: SocketURL,
+	}
+}
+
+// WithEndpoints points the session at a different http origin and socket url, for example a staging mirror or an httptest server
+// Any field left blank keeps the rustchance.com default
+func WithEndpoints(e Endpoints) Option {
+	return func(s *Session) error {
+		if e.HTTP != "" {
+			u, err := url.Parse(e.HTTP)
+			if err != nil {
+				return err
+			}
+			if u.Scheme != "http" && u.Scheme != "https" {
+				return errors.New("http endpoint must be an http or https url")
+			}
+			s.Endpoints.HTTP = strings.TrimSuffix(e.HTTP, "/")
+		}
+		if e.Socket != "" {
+			u, err := url.Parse(e.Socket)
+			if err != nil {
+				return err
+			}
+			if u.Scheme != "ws" && u.Scheme != "wss" {
+				return errors.New("socket endpoint must be a ws or wss url")
+			}
+			s.Endpoints.Socket = e.Socket
+		}
+		return nil
+	}
+}
+
+// WithHTTPClient makes every http request of the session go through c, use this to set your own timeouts, proxies, TLS config or connection pooling
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Session) error {
+		if c == nil {
+			return errors.New("http client can't be nil")
+		}
+		s.HTTPClient = c
+		return nil
+	}
+}
+
+// WithTransport swaps the RoundTripper of the session's http client, it's the easiest way to plug in a proxy or instrumentation while keeping the default timeouts
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *Session) error {
+		if rt == nil {
+			return errors.New("transport can't be nil")
+		}
+		c := s.ownHTTPClient()
+		c.Transport = rt
+		return nil
+	}
+}
+
+// ownHTTPClient makes Session.HTTPClient a copy only this session uses and returns it, options change the copy so a client passed to WithHTTPClient, or http.DefaultClient, is never modified
+func (s *Session) ownHTTPClient() *http.Client {
+	if s.HTTPClient == nil {
+		s.HTTPClient = newHTTPClient()
+		return s.HTTPClient
+	}
+	c := *s.HTTPClient
+	s.HTTPClient = &c
+	return s.HTTPClient
+}
+
+// newHTTPClient returns the client New uses by default, it has its own connection pool so sessions don't share idle connections
+func newHTTPClient() *http.Client {
+	var rt http.RoundTripper = http.DefaultTransport
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		rt = t.Clone()
+	}
+	return &http.Client{
+		Transport: rt,
+		Timeout:   DefaultHTTPTimeout,
+	}
+}
+
+// httpClient returns the client the session should send requests with
+func (s *Session) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return s.HTTPClient
+}
+
+// httpURL joins path onto the configured http origin
+func (s *Session) httpURL(path string) string {
+	origin := s.Endpoints.HTTP
+	if origin == "" {
+		origin = DefaultOrigin
+	}
+	return strings.TrimSuffix(origin, "/") + path
+}
+
+// socketURL returns the configured socket url
+func (s *Session) socketURL() string {
+	if s.Endpoints.Socket == "" {
+		return SocketURL
+	}
+	return s.Endpoints.Socket
+}
+
+// socketHost returns the host of the configured socket url, it's used for the Host header when opening the socket
+func (s *Session) socketHost() string {
+	u, err := url.Parse(s.socketURL())
+	if err != nil || u.Host == "" {
+		return "rustchance.com"
+	}
+	return u.Host
+}
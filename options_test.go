@@ -0,0 +1,40 @@
+package wrapper_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// countingTransport counts the requests that go through it
+type countingTransport struct {
+	n int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.n, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithTransportKeepsCallerClient(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	c := &http.Client{}
+	rt := &countingTransport{}
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(srv.Endpoints()), wrapper.WithHTTPClient(c), wrapper.WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Transport != nil {
+		t.Fatal("WithTransport changed the transport of the client passed to WithHTTPClient")
+	}
+	if _, err := s.GetLeaderboard(); err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if n := atomic.LoadInt32(&rt.n); n != 1 {
+		t.Fatalf("%d requests went through the transport, want 1", n)
+	}
+}
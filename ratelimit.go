@@ -0,0 +1,210 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoint families, requests are rate limited per family, see EndpointFamily
+const (
+	// FamilyAccount is everything about the logged in account, /api/account/*, /profile and /api/affiliates/redeem
+	FamilyAccount = "account"
+	// FamilyLeaderboard is the public tickets leaderboard on /api/bonuses
+	FamilyLeaderboard = "leaderboard"
+	// FamilyHistory is the coinflip and jackpot history on /api/history/
+	FamilyHistory = "history"
+	// FamilyCrash is the crash game lookup on /api/crash/game/
+	FamilyCrash = "crash"
+	// FamilySerial is the provably fair serial lookup on /api/serial/
+	FamilySerial = "serial"
+	// FamilyOther is any path the wrapper doesn't know about
+	FamilyOther = "other"
+)
+
+// DefaultRetryAfter is how long a family is held back after a 429 that didn't have a Retry-After header
+const DefaultRetryAfter = time.Second
+
+// RateLimit is a token bucket, Burst requests can go out at once and after that Rate requests per second
+type RateLimit struct {
+	// Rate is how many requests per second the bucket refills, zero doesn't limit the family but a 429 still holds it back
+	Rate float64
+	// Burst is how many requests can go out back to back, anything below 1 is 1
+	Burst int
+}
+
+// RateLimitError is returned when a request would have to wait for the rate limiter past the deadline of its context, the request isn't sent
+// It matches ErrRateLimited with errors.Is
+type RateLimitError struct {
+	// Endpoint is the path of the request, for example "/api/history/coinflip"
+	Endpoint string
+	// Family is the endpoint family the request was limited by
+	Family string
+	// Wait is how long the request would have had to wait
+	Wait time.Duration
+}
+
+// Error formats the error like "/api/history/coinflip rate limited, would have to wait 1.5s"
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limited, would have to wait %s", e.Endpoint, e.Wait)
+}
+
+// Unwrap returns ErrRateLimited so errors.Is works
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// WithRateLimit limits requests to an endpoint family like FamilyHistory to rate per second with bursts of burst, it can be passed once per family
+func WithRateLimit(family string, rate float64, burst int) Option {
+	return func(s *Session) error {
+		if rate < 0 {
+			return errors.New("rate limit can't be negative")
+		}
+		if s.RateLimits == nil {
+			s.RateLimits = make(map[string]RateLimit)
+		}
+		s.RateLimits[family] = RateLimit{Rate: rate, Burst: burst}
+		return nil
+	}
+}
+
+// EndpointFamily returns the family a request path is rate limited under
+func EndpointFamily(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/account/"), path == AccountProfilePath, path == RedeemCodePath:
+		return FamilyAccount
+	case path == TicketsLeaderboardPath:
+		return FamilyLeaderboard
+	case strings.HasPrefix(path, HistoryAPIPath):
+		return FamilyHistory
+	case strings.HasPrefix(path, CrashGamePath):
+		return FamilyCrash
+	case strings.HasPrefix(path, ProvefairSerialPath):
+		return FamilySerial
+	}
+	return FamilyOther
+}
+
+// bucket is the limiter state of one family
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+	// until is when a 429 stops holding the family back
+	until time.Time
+}
+
+// reserve takes a token and returns how long the caller has to wait before it can use it
+func (b *bucket) reserve(now time.Time) time.Duration {
+	var wait time.Duration
+	if b.until.After(now) {
+		wait = b.until.Sub(now)
+	}
+	if b.limit.Rate <= 0 {
+		return wait
+	}
+	burst := float64(b.limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens < 0 {
+		if w := time.Duration(-b.tokens / b.limit.Rate * float64(time.Second)); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// cancel gives back a token taken by reserve that wasn't used
+func (b *bucket) cancel() {
+	if b.limit.Rate > 0 {
+		b.tokens++
+	}
+}
+
+// bucket returns the bucket of family, creating it from Session.RateLimits the first time
+func (s *Session) bucket(family string) *bucket {
+	if s.buckets == nil {
+		s.buckets = make(map[string]*bucket)
+	}
+	b := s.buckets[family]
+	if b == nil {
+		limit := s.RateLimits[family]
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+		if b.tokens < 1 {
+			b.tokens = 1
+		}
+		s.buckets[family] = b
+	}
+	return b
+}
+
+// waitRateLimit blocks until a request to path may be sent, it returns a *RateLimitError straight away if that's past the deadline of ctx
+func (s *Session) waitRateLimit(ctx context.Context, path string) error {
+	family := EndpointFamily(path)
+	now := time.Now()
+	s.bucketsMu.Lock()
+	b := s.bucket(family)
+	wait := b.reserve(now)
+	if deadline, ok := ctx.Deadline(); ok && wait > 0 && now.Add(wait).After(deadline) {
+		b.cancel()
+		s.bucketsMu.Unlock()
+		return &RateLimitError{Endpoint: path, Family: family, Wait: wait}
+	}
+	s.bucketsMu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	s.logger().Debug("waiting for rate limit", "endpoint", path, "family", family, "wait", wait)
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		s.bucketsMu.Lock()
+		b.cancel()
+		s.bucketsMu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// holdBack stops requests to the family of path until d has passed, it's called when rustchance answers 429 or 503 with a Retry-After header
+func (s *Session) holdBack(path string, d time.Duration) {
+	family := EndpointFamily(path)
+	s.bucketsMu.Lock()
+	b := s.bucket(family)
+	if until := time.Now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+	s.bucketsMu.Unlock()
+	s.logger().Warn("rate limited by rustchance", "endpoint", path, "family", family, "retry_after", d)
+}
+
+// retryAfter reads the Retry-After header of resp, it can be a number of seconds or an http date, fallback is used when it's missing or invalid
+func retryAfter(resp *http.Response, fallback time.Duration) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return fallback
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return fallback
+}
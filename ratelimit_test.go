@@ -0,0 +1,127 @@
+package wrapper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+)
+
+func TestRateLimitBucket(t *testing.T) {
+	srv, attempts := countingServer(t, func(http.ResponseWriter, *http.Request, int32) bool { return false })
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL}), wrapper.WithRateLimit(wrapper.FamilyHistory, 20, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := s.GetCoinflipHistory(); err != nil {
+			t.Fatalf("GetCoinflipHistory: %v", err)
+		}
+	}
+	// two go out as the burst, the other two wait a twentieth of a second each
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("4 requests at 20/s with a burst of 2 took %s, want at least 100ms", d)
+	}
+	if n := atomic.LoadInt32(attempts); n != 4 {
+		t.Fatalf("server saw %d requests, want 4", n)
+	}
+	// other families aren't limited
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := s.GetLeaderboard(); err != nil {
+			t.Fatalf("GetLeaderboard: %v", err)
+		}
+	}
+	if d := time.Since(start); d > 80*time.Millisecond {
+		t.Fatalf("unlimited family took %s for 4 requests", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		header   func() string
+		min, max time.Duration
+	}{
+		{"seconds", http.StatusTooManyRequests, func() string { return "2" }, 2 * time.Second, 2 * time.Second},
+		{"http date", http.StatusTooManyRequests, func() string { return time.Now().Add(3 * time.Second).UTC().Format(http.TimeFormat) }, time.Second, 3 * time.Second},
+		{"missing", http.StatusTooManyRequests, func() string { return "" }, wrapper.DefaultRetryAfter, wrapper.DefaultRetryAfter},
+		{"invalid", http.StatusTooManyRequests, func() string { return "soon" }, wrapper.DefaultRetryAfter, wrapper.DefaultRetryAfter},
+		{"503", http.StatusServiceUnavailable, func() string { return "2" }, 2 * time.Second, 2 * time.Second},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			srv, attempts := countingServer(t, func(w http.ResponseWriter, r *http.Request, attempt int32) bool {
+				if v := c.header(); v != "" {
+					w.Header().Set("Retry-After", v)
+				}
+				w.WriteHeader(c.status)
+				return true
+			})
+			s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL}), wrapper.WithRetry(wrapper.RetryPolicy{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.GetCoinflipHistory()
+			var apiErr *wrapper.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != c.status {
+				t.Fatalf("GetCoinflipHistory = %v, want an APIError with %d", err, c.status)
+			}
+			if apiErr.RetryAfter < c.min || apiErr.RetryAfter > c.max {
+				t.Fatalf("RetryAfter = %s, want between %s and %s", apiErr.RetryAfter, c.min, c.max)
+			}
+
+			// the family is held back, a request that can't wait that long fails without being sent
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			_, err = s.GetCoinflipHistoryCtx(ctx)
+			var rl *wrapper.RateLimitError
+			if !errors.As(err, &rl) || !errors.Is(err, wrapper.ErrRateLimited) {
+				t.Fatalf("request while held back = %v, want a RateLimitError", err)
+			}
+			if rl.Family != wrapper.FamilyHistory || rl.Wait <= 200*time.Millisecond {
+				t.Fatalf("RateLimitError = %+v, want family history and a wait past the deadline", rl)
+			}
+			if n := atomic.LoadInt32(attempts); n != 1 {
+				t.Fatalf("server saw %d requests, want only the first", n)
+			}
+			// other families aren't held back
+			if _, err := s.GetLeaderboard(); err != nil && !errors.As(err, &apiErr) {
+				t.Fatalf("GetLeaderboard was held back: %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryWaitsForRetryAfter(t *testing.T) {
+	srv, attempts := countingServer(t, func(w http.ResponseWriter, r *http.Request, attempt int32) bool {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", strconv.Itoa(1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return true
+		}
+		return false
+	})
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL}), wrapper.WithRetry(quickRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := s.GetCoinflipHistory(); err != nil {
+		t.Fatalf("GetCoinflipHistory: %v", err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Fatalf("retry went out after %s, before the Retry-After of 1s", d)
+	}
+	if n := atomic.LoadInt32(attempts); n != 2 {
+		t.Fatalf("server saw %d requests, want 2", n)
+	}
+}
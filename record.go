@@ -0,0 +1,183 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction says whether a recorded frame was received from or sent to the socket
+type Direction string
+
+const (
+	// Inbound is a frame the session read from the socket
+	Inbound Direction = "in"
+	// Outbound is a payload the session wrote to the socket
+	Outbound Direction = "out"
+)
+
+// Frame is one websocket message as it went over the wire, it's a single line of a recording
+type Frame struct {
+	// Time is when the frame was read or written
+	Time time.Time `json:"time"`
+	// Direction is whether the frame was received or sent
+	Direction Direction `json:"dir"`
+	// Data is the exact message, a received message can hold several payloads separated by newlines
+	Data string `json:"data"`
+}
+
+// Recorder receives every frame the session reads or writes when it's set on Session.Recorder, it's called from the read loop so it should be quick
+type Recorder interface {
+	Record(f Frame) error
+}
+
+// record hands a frame to Session.Recorder if one is set
+func (s *Session) record(dir Direction, data []byte) {
+	if s.Recorder == nil {
+		return
+	}
+	err := s.Recorder.Record(Frame{
+		Time:      time.Now(),
+		Direction: dir,
+		Data:      string(data),
+	})
+	if err != nil {
+		s.logger().Warn("recording frame failed", "direction", string(dir), "size", len(data), "error", err)
+	}
+}
+
+// FileRecorder writes frames to a JSONL file, one Frame per line
+// When the file grows past MaxBytes it's renamed with a timestamp and a new file is started, only the newest MaxBackups renamed files are kept
+type FileRecorder struct {
+	// Path is the file frames are appended to, for example "capture.jsonl"
+	Path string
+	// MaxBytes is the size a file can grow to before it's rotated, zero means it's never rotated
+	MaxBytes int64
+	// MaxBackups is how many rotated files to keep, zero keeps all of them
+	MaxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileRecorder opens path for appending and returns a recorder that rotates it at maxBytes keeping maxBackups old files
+func NewFileRecorder(path string, maxBytes int64, maxBackups int) (*FileRecorder, error) {
+	r := &FileRecorder{
+		Path:       path,
+		MaxBytes:   maxBytes,
+		MaxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends f as a json line, rotating the file first if the line would push it past MaxBytes
+func (r *FileRecorder) Record(f Frame) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.MaxBytes > 0 && r.size > 0 && r.size+int64(len(b)) > r.MaxBytes {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return err
+}
+
+// Close closes the current file, a later Record opens it again
+func (r *FileRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// open opens Path for appending and picks up its current size
+func (r *FileRecorder) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotateLayout is the timestamp rotate puts in the name of a rotated file
+const rotateLayout = "20060102T150405.000000"
+
+// rotate renames the current file to name-20060102T150405.000000.ext, starts a new one and removes backups past MaxBackups
+func (r *FileRecorder) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	ext := filepath.Ext(r.Path)
+	base := strings.TrimSuffix(r.Path, ext)
+	rotated := base + "-" + time.Now().UTC().Format(rotateLayout) + ext
+	if err := os.Rename(r.Path, rotated); err != nil {
+		return err
+	}
+	if r.MaxBackups > 0 {
+		old, err := r.backups()
+		if err == nil && len(old) > r.MaxBackups {
+			sort.Strings(old)
+			for _, name := range old[:len(old)-r.MaxBackups] {
+				os.Remove(name)
+			}
+		}
+	}
+	return r.open()
+}
+
+// backups returns the files rotate renamed Path to, a file only counts when the part between the name and the extension is a rotate timestamp
+// That way files that just share the prefix, like capture-old.jsonl next to capture.jsonl, are never removed
+func (r *FileRecorder) backups() ([]string, error) {
+	ext := filepath.Ext(r.Path)
+	prefix := strings.TrimSuffix(filepath.Base(r.Path), ext) + "-"
+	dir := filepath.Dir(r.Path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(rotateLayout, stamp); err != nil {
+			continue
+		}
+		out = append(out, filepath.Join(dir, name))
+	}
+	return out, nil
+}
@@ -0,0 +1,52 @@
+package wrapper_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+)
+
+func TestFileRecorderRotateKeepsOtherFiles(t *testing.T) {
+	for _, name := range []string{"capture.jsonl", "feed"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, name)
+			ext := filepath.Ext(name)
+			other := strings.TrimSuffix(path, ext) + "-old" + ext
+			if err := os.WriteFile(other, []byte("keep me\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			r, err := wrapper.NewFileRecorder(path, 64, 1)
+			if err != nil {
+				t.Fatalf("NewFileRecorder: %v", err)
+			}
+			defer r.Close()
+			for i := 0; i < 4; i++ {
+				if err := r.Record(wrapper.Frame{Time: time.Now(), Direction: wrapper.Inbound, Data: "0123456789"}); err != nil {
+					t.Fatalf("Record: %v", err)
+				}
+				// rotated names are only unique to the microsecond
+				time.Sleep(2 * time.Millisecond)
+			}
+			if _, err := os.Stat(other); err != nil {
+				t.Fatalf("%s was removed by rotate: %v", filepath.Base(other), err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the live file, one backup and the unrelated file
+			if len(entries) != 3 {
+				var names []string
+				for _, e := range entries {
+					names = append(names, e.Name())
+				}
+				t.Fatalf("dir has %v, want the file, one backup and %s", names, filepath.Base(other))
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package wrapper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// MaxReplayFrame is the longest line Replay accepts, rustchance list payloads can get big
+const MaxReplayFrame = 16 << 20
+
+// ReplayOptions configures Replay
+type ReplayOptions struct {
+	// Speed is how fast the recording is played back, 1 keeps the original pace, 10 is ten times faster and 0 replays as fast as possible
+	Speed float64
+}
+
+// Replay reads a recording written by FileRecorder and feeds every received frame through the same decode and dispatch path as a live socket
+// Handlers, subscriptions and the drift detector all see the frames as if they came from rustchance, sent frames are skipped and nothing is passed to Session.Recorder
+// Connected is emitted before the first frame and Disconnected with io.EOF after the last one, Replay returns nil once the recording is done
+func (s *Session) Replay(ctx context.Context, r io.Reader, opts ReplayOptions) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), MaxReplayFrame)
+	s.emit("socket_connected", &Connected{})
+	var last time.Time
+	err := func() error {
+		for sc.Scan() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var f Frame
+			if err := json.Unmarshal(sc.Bytes(), &f); err != nil {
+				return err
+			}
+			if f.Direction != Inbound {
+				continue
+			}
+			if opts.Speed > 0 && !last.IsZero() && f.Time.After(last) {
+				t := time.NewTimer(time.Duration(float64(f.Time.Sub(last)) / opts.Speed))
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return ctx.Err()
+				case <-t.C:
+				}
+			}
+			last = f.Time
+			s.onMessage([]byte(f.Data))
+		}
+		if err := sc.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}()
+	s.emit("socket_disconnected", &Disconnected{Err: err})
+	s.drain()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// ReplayFile is Replay reading from the file at path
+func (s *Session) ReplayFile(ctx context.Context, path string, opts ReplayOptions) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Replay(ctx, f, opts)
+}
+
+// drain waits for handlers still running from DispatchGoroutine or DispatchPool when the socket isn't open, a live socket drains them itself when it closes
+func (s *Session) drain() {
+	s.runMu.Lock()
+	running := s.running != nil
+	s.runMu.Unlock()
+	if running {
+		return
+	}
+	s.stopPool()
+	s.wg.Wait()
+}
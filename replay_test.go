@@ -0,0 +1,106 @@
+package wrapper_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// recordTicks records a live session that gets crash ticks 1 and 2 300ms apart and sends a tick of its own, it returns the recording's path
+func recordTicks(t *testing.T) string {
+	t.Helper()
+	srv := mock.NewServer()
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "feed.jsonl")
+	rec, err := wrapper.NewFileRecorder(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFileRecorder: %v", err)
+	}
+	defer rec.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash"))
+	s.Recorder = rec
+	ticks := make(chan struct{}, 2)
+	s.AddHandler(func(_ *wrapper.Session, _ *wrapper.CrashTick) {
+		notify(ticks)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	srv.Emit("crash", "tick", 1)
+	waitFor(t, ticks, "the first tick")
+	// a sent frame that would decode as a tick if replay didn't skip it
+	if err := s.Write(&wrapper.Payload{Room: "crash", Type: "tick", Data: 99}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	srv.Emit("crash", "tick", 2)
+	waitFor(t, ticks, "the second tick")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	path := recordTicks(t)
+	cases := []struct {
+		speed    float64
+		min, max time.Duration
+	}{
+		{speed: 1, min: 300 * time.Millisecond, max: 5 * time.Second},
+		{speed: 10, min: 30 * time.Millisecond, max: 250 * time.Millisecond},
+		{speed: 0, max: 250 * time.Millisecond},
+	}
+	for _, c := range cases {
+		s, err := wrapper.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ticks []int
+		s.AddHandler(func(_ *wrapper.Session, e *wrapper.CrashTick) {
+			ticks = append(ticks, e.Data)
+		})
+		connected := false
+		s.AddHandler(func(_ *wrapper.Session, _ *wrapper.Connected) {
+			connected = true
+		})
+		var disconnected error
+		s.AddHandler(func(_ *wrapper.Session, d *wrapper.Disconnected) {
+			disconnected = d.Err
+		})
+		start := time.Now()
+		if err := s.ReplayFile(context.Background(), path, wrapper.ReplayOptions{Speed: c.speed}); err != nil {
+			t.Fatalf("ReplayFile at speed %v: %v", c.speed, err)
+		}
+		took := time.Since(start)
+		if len(ticks) != 2 || ticks[0] != 1 || ticks[1] != 2 {
+			t.Fatalf("replay at speed %v dispatched ticks %v, want the received 1 and 2 only", c.speed, ticks)
+		}
+		if !connected || disconnected != io.EOF {
+			t.Fatalf("replay at speed %v: connected %v, disconnected with %v, want Connected and io.EOF", c.speed, connected, disconnected)
+		}
+		if took < c.min || took > c.max {
+			t.Fatalf("replay at speed %v took %s, want between %s and %s", c.speed, took, c.min, c.max)
+		}
+	}
+}
+
+func TestReplayCancel(t *testing.T) {
+	path := recordTicks(t)
+	s, err := wrapper.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// the gap between the ticks is cut short by the context
+	if err := s.ReplayFile(ctx, path, wrapper.ReplayOptions{Speed: 1}); err != context.DeadlineExceeded {
+		t.Fatalf("ReplayFile = %v, want context.DeadlineExceeded", err)
+	}
+}
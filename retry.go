@@ -0,0 +1,122 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy says how GetBody retries requests that failed for a reason that might go away, like a dropped connection or a 503
+// Only GET and HEAD requests are retried, a POST like ClaimFaucet or RedeemCode is never sent twice because it could claim twice
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is sent at most, zero or one turns retrying off
+	MaxAttempts int
+	// Backoff is the delay between attempts, any field left at zero uses the value from DefaultBackoff
+	Backoff Backoff
+	// Statuses are the status codes worth retrying, nil means 429, 502, 503 and 504
+	Statuses []int
+}
+
+// DefaultRetryPolicy is what New sets on Session.Retry, three attempts a quarter second and half a second apart give or take jitter
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff: Backoff{
+		Min:    250 * time.Millisecond,
+		Max:    5 * time.Second,
+		Factor: 2,
+		Jitter: 0.2,
+	},
+}
+
+// defaultRetryStatuses is used when RetryPolicy.Statuses is nil
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// WithRetry sets the retry policy of the session, pass RetryPolicy{} to turn retrying off
+func WithRetry(p RetryPolicy) Option {
+	return func(s *Session) error {
+		if p.MaxAttempts < 0 {
+			return errors.New("retry attempts can't be negative")
+		}
+		s.Retry = p
+		return nil
+	}
+}
+
+// idempotent says whether sending req twice is harmless
+func idempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// retryable says whether err from an attempt is worth another one, ctx errors and errors like ErrNoAuthToken aren't
+// Transport errors only count when the connection itself failed, see transient
+func (p RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		statuses := p.Statuses
+		if statuses == nil {
+			statuses = defaultRetryStatuses
+		}
+		for _, code := range statuses {
+			if apiErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+	return transient(err)
+}
+
+// transient says whether a transport error is the connection failing in a way that might not happen again, like a refused or reset connection
+// The client's own Timeout isn't one of them since another attempt would wait just as long, neither are TLS errors or a bad url
+func transient(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// the client's Timeout comes back as its own error, a *net.OpError timing out is a dial or read deadline below it
+	var op *net.OpError
+	if errors.As(err, &op) {
+		return op.Timeout() || op.Temporary()
+	}
+	return false
+}
+
+// delay returns how long to wait before attempt, a Retry-After from the last response wins when it's longer than the backoff
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := p.Backoff.Delay(attempt)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+	}
+	return d
+}
+
+// sleepRetry waits d before the next attempt, it reports false when ctx is done first or its deadline is closer than d
+func sleepRetry(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(d).After(deadline) {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,121 @@
+package wrapper_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// quickRetry is DefaultRetryPolicy without the waiting
+var quickRetry = wrapper.RetryPolicy{MaxAttempts: 3, Backoff: wrapper.Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 1}}
+
+// countingServer serves the mock fixtures after calling handle, handle returns true when it answered the request itself
+func countingServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request, attempt int32) bool) (*httptest.Server, *int32) {
+	t.Helper()
+	fixtures := mock.NewServer()
+	t.Cleanup(fixtures.Close)
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle(w, r, atomic.AddInt32(&attempts, 1)) {
+			return
+		}
+		resp, err := http.Get(fixtures.URL + r.URL.RequestURI())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := resp.Body.Read(buf)
+			w.Write(buf[:n])
+			if err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &attempts
+}
+
+func TestRetryTransient(t *testing.T) {
+	srv, attempts := countingServer(t, func(w http.ResponseWriter, r *http.Request, attempt int32) bool {
+		switch attempt {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		case 2:
+			// drop the connection without an answer
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+			return true
+		}
+		return false
+	})
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL}), wrapper.WithRetry(quickRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetLeaderboard(); err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if n := atomic.LoadInt32(attempts); n != 3 {
+		t.Fatalf("%d attempts, want 3", n)
+	}
+}
+
+func TestRetryClientTimeout(t *testing.T) {
+	srv, attempts := countingServer(t, func(w http.ResponseWriter, r *http.Request, attempt int32) bool {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		return true
+	})
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL}), wrapper.WithHTTPClient(client), wrapper.WithRetry(quickRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetLeaderboardCtx(context.Background()); err == nil {
+		t.Fatal("GetLeaderboard worked against a server that never answers")
+	}
+	if n := atomic.LoadInt32(attempts); n != 1 {
+		t.Fatalf("%d attempts after the client timed out, want 1", n)
+	}
+}
+
+func TestRetryNotTransient(t *testing.T) {
+	// the test server's certificate isn't trusted by the session's client, that won't change on another attempt
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{HTTP: srv.URL}), wrapper.WithRetry(quickRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetLeaderboard(); err == nil {
+		t.Fatal("GetLeaderboard worked against a server with an untrusted certificate")
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("server saw %d connections, want 1", n)
+	}
+}
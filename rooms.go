@@ -0,0 +1,90 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// JoinRooms starts listening to rooms on the live socket and adds them to Session.Rooms so they're joined again after a reconnect
+// Every room has to be one of KnownRooms, rooms that are already joined are skipped, if the socket isn't open the rooms are joined by the next Open
+func (s *Session) JoinRooms(rooms ...string) error {
+	return s.JoinRoomsCtx(context.Background(), rooms...)
+}
+
+// JoinRoomsCtx is JoinRooms with a context
+func (s *Session) JoinRoomsCtx(ctx context.Context, rooms ...string) error {
+	if err := checkRooms(rooms); err != nil {
+		return err
+	}
+	s.roomsMu.Lock()
+	var added []string
+	for _, room := range rooms {
+		if !contains(s.Rooms, room) && !contains(added, room) {
+			added = append(added, room)
+		}
+	}
+	s.Rooms = append(s.Rooms, added...)
+	s.roomsMu.Unlock()
+	return s.writeRooms(ctx, "join_rooms", added)
+}
+
+// LeaveRooms stops listening to rooms on the live socket and removes them from Session.Rooms so they're not joined again after a reconnect
+// Rooms that aren't joined are skipped, if the socket isn't open the rooms are just left out of the next Open
+func (s *Session) LeaveRooms(rooms ...string) error {
+	return s.LeaveRoomsCtx(context.Background(), rooms...)
+}
+
+// LeaveRoomsCtx is LeaveRooms with a context
+func (s *Session) LeaveRoomsCtx(ctx context.Context, rooms ...string) error {
+	if err := checkRooms(rooms); err != nil {
+		return err
+	}
+	s.roomsMu.Lock()
+	var removed []string
+	kept := make([]string, 0, len(s.Rooms))
+	for _, room := range s.Rooms {
+		if contains(rooms, room) {
+			removed = append(removed, room)
+		} else {
+			kept = append(kept, room)
+		}
+	}
+	s.Rooms = kept
+	s.roomsMu.Unlock()
+	return s.writeRooms(ctx, "leave_rooms", removed)
+}
+
+// JoinedRooms returns a copy of Session.Rooms that's safe to use while JoinRooms and LeaveRooms are called
+func (s *Session) JoinedRooms() []string {
+	s.roomsMu.Lock()
+	defer s.roomsMu.Unlock()
+	return append([]string(nil), s.Rooms...)
+}
+
+// writeRooms sends a join_rooms or leave_rooms control payload if there's anything to send
+// ErrNotConnected isn't an error here because the next connection joins Session.Rooms anyway
+func (s *Session) writeRooms(ctx context.Context, t string, rooms []string) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	err := s.WriteCtx(ctx, &Payload{
+		Data: rooms,
+		Room: "control",
+		Type: t,
+	})
+	if err == ErrNotConnected {
+		return nil
+	}
+	return err
+}
+
+// checkRooms returns an error naming the first room that isn't one of KnownRooms
+func checkRooms(rooms []string) error {
+	for _, room := range rooms {
+		if !contains(KnownRooms, room) {
+			return fmt.Errorf("unknown room %q, known rooms are %s", room, strings.Join(KnownRooms, ", "))
+		}
+	}
+	return nil
+}
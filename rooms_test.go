@@ -0,0 +1,73 @@
+package wrapper_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// clientRooms returns the rooms the only client of srv joined, sorted and joined with commas
+func clientRooms(srv *mock.Server) string {
+	clients := srv.Clients()
+	if len(clients) != 1 {
+		return ""
+	}
+	rooms := clients[0].Rooms()
+	sort.Strings(rooms)
+	return strings.Join(rooms, ",")
+}
+
+func TestRoomsAfterReconnect(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash", "chat"))
+	resumed := make(chan struct{}, 1)
+	s.AddHandler(func(_ *wrapper.Session, _ *wrapper.Resumed) {
+		notify(resumed)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	if err := s.JoinRooms("roulette", "crash"); err != nil {
+		t.Fatalf("JoinRooms: %v", err)
+	}
+	if err := s.LeaveRooms("chat"); err != nil {
+		t.Fatalf("LeaveRooms: %v", err)
+	}
+	eventually(t, "the live socket to be in crash and roulette", func() bool {
+		return clientRooms(srv) == "crash,roulette"
+	})
+	if got := strings.Join(s.JoinedRooms(), ","); got != "crash,roulette" {
+		t.Fatalf("JoinedRooms = %s, want crash,roulette", got)
+	}
+
+	srv.DropClients()
+	waitFor(t, resumed, "the socket to resume")
+	waitJoin(t, srv, 1)
+	if got := clientRooms(srv); got != "crash,roulette" {
+		t.Fatalf("rooms after the reconnect = %q, want crash,roulette", got)
+	}
+}
+
+func TestJoinRoomsBeforeOpen(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash"))
+	if err := s.JoinRooms("jackpot"); err != nil {
+		t.Fatalf("JoinRooms before Open: %v", err)
+	}
+	if err := s.JoinRooms("casino"); err == nil {
+		t.Fatal("JoinRooms took an unknown room")
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	if got := clientRooms(srv); got != "crash,jackpot" {
+		t.Fatalf("rooms = %q, want crash,jackpot", got)
+	}
+}
@@ -1,546 +1,556 @@
 package wrapper
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// New returns a *Session and err
+// NewSession returns a *Session configured by opts, with no options it's an unauthorized session that joins every room in KnownRooms and the "en" chat room
+// Options are checked as they're applied, the first one that fails is returned as the error, see WithToken, WithRooms and WithChatRoom
+func NewSession(opts ...Option) (*Session, error) {
+	s := &Session{
+		Endpoints:    DefaultEndpoints(),
+		HTTPClient:   newHTTPClient(),
+		Retry:        DefaultRetryPolicy,
+		PingInterval: DefaultPingInterval,
+		ReadTimeout:  DefaultReadTimeout,
+		WriteTimeout: DefaultWriteTimeout,
+		Rooms:        append([]string(nil), KnownRooms...),
+		Room:         "en",
+		handlers:     make(map[string][]*eventHandler),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	s.setDefaultHeaders()
+	return s, nil
+}
+
+// New returns a *Session and err, it's NewSession with the token, rooms and chat room given as arguments
 // Token is your auth token, this can be left empty, you only need a token for account specific things
-// Rooms is a list of rooms to join, assumed to join all but you can set specific rooms. The current all known rooms is "chat", "crash", "shop", "coinflip", "jackpot", "jackpot-low", "supply-drops", "mines"
+// Rooms is a list of rooms to join, if it's empty every room in KnownRooms is joined
 // Room can either be "en", "tr", or "ru". If none is supplied it assumes "en"
-func New(token string, rooms []string, room string) (*Session, error) {
-	s := &Session{}
+// Options can change things like the endpoints the session talks to or the http client it uses, see WithEndpoints and WithHTTPClient
+func New(token string, rooms []string, room string, opts ...Option) (*Session, error) {
+	var base []Option
 	if token != "" {
-		s.Auth = token
+		base = append(base, WithToken(token))
 	}
-	headers := strings.Split("Host: rustchance.com\nPragma: no-cache\nCache-Control: no-cache\nUser-Agent: Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36 OPR/73.0.3856.421\nOrigin: https://rustchance.com\nSec-WebSocket-Version: 13\nAccept-Encoding: gzip, deflate, br\nAccept-Language: en-US,en;q=0.9,zh;q=0.8\nSec-WebSocket-Extensions: permessage-deflate; client_max_window_bits", "\n")
-	if s.Auth != "" {
-		headers = append(headers, "Cookie: token="+s.Auth)
+	if len(rooms) > 0 {
+		base = append(base, WithRooms(rooms...))
 	}
-	s.Headers = http.Header{}
-	for _, header := range headers {
-		parts := strings.Split(header, ": ")
-		if len(parts) == 2 {
-			s.Headers[parts[0]] = []string{parts[1]}
-		}
+	if room != "" {
+		base = append(base, WithChatRoom(room))
 	}
-	if len(rooms) < 1 {
-		s.Rooms = []string{"chat", "crash", "shop", "coinflip", "jackpot", "jackpot-low", "supply-drops", "mines"}
-	} else {
-		s.Rooms = rooms
+	return NewSession(append(base, opts...)...)
+}
+
+// setDefaultHeaders fills in the socket handshake headers that weren't set by an option
+func (s *Session) setDefaultHeaders() {
+	if s.Headers == nil {
+		s.Headers = http.Header{}
 	}
-	if room != "" {
-		s.Room = room
-	} else {
-		s.Room = "en"
+	defaults := map[string]string{
+		"Host":            s.socketHost(),
+		"Origin":          s.httpURL(""),
+		"User-Agent":      DefaultUserAgent,
+		"Pragma":          "no-cache",
+		"Cache-Control":   "no-cache",
+		"Accept-Language": "en-US,en;q=0.9",
+	}
+	if s.Auth != "" {
+		defaults["Cookie"] = "token=" + s.Auth
+	}
+	for k, v := range defaults {
+		if s.Headers.Get(k) == "" {
+			s.Headers.Set(k, v)
+		}
 	}
-	s.Log = false
-	s.Handlers = make(map[string]func(*Session, interface{}))
-	return s, nil
 }
 
 // Write writes a payload to the websocket, this is usually only used by the package but can be used by a user directly.
 // toWrite should be a json payload unmarshal'd
-// returns an error incase writing fails
+// returns an error incase writing fails, ErrNotConnected if the socket isn't open and ErrWriteTimeout if it took longer than Session.WriteTimeout
 func (s *Session) Write(toWrite interface{}) error {
-	s.SocketMutex.Lock()
-	err := s.Socket.WriteJSON(toWrite)
-	s.SocketMutex.Unlock()
-	return err
+	return s.WriteCtx(context.Background(), toWrite)
 }
 
-// Open opens the websocket connection and writes the initial payload as well as starts reading from the socket.
+// WriteCtx is Write with a context, the write gives up at the deadline of ctx or after Session.WriteTimeout, whichever comes first, or when ctx is cancelled
+// A write that returned an error is never sent later, if the writer already had it the call waits for the real result instead
+// Payloads go through a queue that a single writer drains so callers never write to the socket themselves, ErrNotConnected is returned when the socket isn't open
+func (s *Session) WriteCtx(ctx context.Context, toWrite interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b, err := marshalPayload(toWrite)
+	if err != nil {
+		return err
+	}
+	r, c, err := s.queueFor()
+	if err != nil {
+		return err
+	}
+	w := s.newOutgoing(ctx, c, b, true)
+	var timeout <-chan time.Time
+	if !w.deadline.IsZero() {
+		t := time.NewTimer(time.Until(w.deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case r.out <- w:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		return ErrWriteTimeout
+	case <-r.done:
+		return ErrNotConnected
+	}
+	var gaveUp error
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+		gaveUp = ctx.Err()
+	case <-timeout:
+		gaveUp = ErrWriteTimeout
+	case <-r.done:
+		gaveUp = ErrNotConnected
+	}
+	// the payload stays in the queue, it's only safe to report the write as failed if the writer hasn't taken it yet
+	if w.abandon() {
+		return gaveUp
+	}
+	return <-w.done
+}
+
+// marshalPayload turns a payload into a text frame
+func marshalPayload(toWrite interface{}) ([]byte, error) {
+	b, err := json.Marshal(toWrite)
+	if err != nil {
+		return nil, err
+	}
+	// WriteJSON ends every payload with a newline, keep doing that so the frames stay the same
+	return append(b, '\n'), nil
+}
+
+// Open opens the websocket connection and writes the join_rooms payload and the chat room, then returns while reading happens in the background
+// If the connection drops it is reopened with an exponential backoff (see Session.Reconnect) until Close is called
 func (s *Session) Open() error {
-	if c, _, err := websocket.DefaultDialer.Dial(SocketURL, s.Headers); err == nil {
+	return s.OpenCtx(context.Background())
+}
 
-		s.Socket = c
-		err = s.Write(&Payload{
-			Data: s.Rooms,
-			Room: "control",
-			Type: "join_rooms",
-		})
-		if err != nil && s.Log {
-			fmt.Println(err)
-		}
-		for {
-			_, message, err := c.ReadMessage()
-
-			if err != nil {
-				c.Close()
-				s.Socket = nil
-				s.Open()
-				return err
-			}
-			for _, msg := range strings.Split(string(message), "\n") {
-				var m Payload
-				err = json.Unmarshal([]byte(msg), &m)
-				t := m.Room + "_" + m.Type
-				if f, ok := s.Handlers[t]; ok {
-					switch t {
-					case "shop_rules":
-						p := &ShopRules{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "chat_rooms":
-						p := &ChatRooms{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "chat_message":
-						p := &ChatMessage{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "chat_stats":
-						p := &ChatStats{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "coinflip_delete_game":
-						p := &CoinflipDeleteGame{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "coinflip_game_status":
-						p := &CoinflipGameStatus{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "coinflip_list":
-						p := &CoinflipList{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "coinflip_new_game":
-						p := &CoinflipNewGame{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "coinflip_update_game":
-						p := &CoinflipUpdateGame{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "crash_cashout":
-						p := &CrashCashOut{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "crash_multiple_bets":
-						p := &CrashMultipleBets{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "crash_new":
-						p := &CrashNew{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "crash_start":
-						p := &CrashStart{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "crash_tick":
-						p := &CrashTick{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot_list":
-						p := &JackpotList{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot_new_deposit":
-						p := &JackpotNewDeposit{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot_new_game":
-						p := &JackpotNewGame{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot_start_timer":
-						p := &JackpotStartTimer{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot-low_list":
-						p := &LowJackpotList{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot-low_new_deposit":
-						p := &LowJackpotNewDeposit{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot-low_new_game":
-						p := &LowJackpotNewGame{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "jackpot-low_start_timer":
-						p := &LowJackpotStartTimer{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_begin_timer":
-						p := &MinesBeginTimer{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_game_started":
-						p := &MinesGameStarted{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_game_starting":
-						p := &MinesGameStarting{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_list":
-						p := &MinesList{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_new_game":
-						p := &MinesNewGame{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_new_player":
-						p := &MinesNewPlayer{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "mines_winner":
-						p := &MinesWinner{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "supply-drops_joinable":
-						p := &SupplyDropsJoinable{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "supply-drops_list":
-						p := &SupplyDropsList{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "supply-drops_players":
-						p := &SupplyDropsPlayers{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "supply-drops_result":
-						p := &SupplyDropWinner{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "roulette_roll":
-						p := &RouletteRoll{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "roulette_list":
-						p := &RouletteList{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					case "user_set_points":
-						p := &UserSetPoints{}
-						err = json.Unmarshal([]byte(msg), p)
-						if err != nil && s.Log {
-							fmt.Println(err)
-							break
-						}
-						f(s, p)
-					default:
-						break
-
-					}
-				}
-			}
+// OpenCtx is Open with a context, the context bounds the whole life of the connection, cancelling it is the same as calling Close without waiting
+// The session counts as open while it dials so Close can abort a dial that hangs and writes don't wait on it
+func (s *Session) OpenCtx(ctx context.Context) error {
+	s.runMu.Lock()
+	if s.running != nil {
+		s.runMu.Unlock()
+		return ErrAlreadyOpen
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	size := s.WriteQueue
+	if size <= 0 {
+		size = DefaultWriteQueue
+	}
+	r := &run{
+		cancel: cancel,
+		out:    make(chan *outgoing, size),
+		done:   ctx.Done(),
+	}
+	s.running = r
+	s.wg.Add(1)
+	s.runMu.Unlock()
 
-		}
-	} else {
+	c, err := s.connect(ctx)
+	if err == nil && ctx.Err() != nil {
+		// Close was called right as the dial finished
+		s.clearSocket(c)
+		c.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		s.runMu.Lock()
+		if s.running == r {
+			s.running = nil
+		}
+		s.runMu.Unlock()
+		cancel()
+		failQueued(r.out, ErrNotConnected)
+		s.wg.Done()
 		return err
 	}
+	go s.run(ctx, r, c)
+	return nil
 }
 
-// AddHandler sets something to do when an event happens, the input is a func that always has a first argument of a *Session and a second argument of another struct
-func (s *Session) AddHandler(v interface{}) {
-	switch a := v.(type) {
-	case func(*Session, *ShopRules):
-		s.Handlers["shop_rules"] = func(s *Session, v interface{}) {
-			a(s, v.(*ShopRules))
-		}
-	case func(*Session, *ChatRooms):
-		s.Handlers["chat_rooms"] = func(s *Session, v interface{}) {
-			a(s, v.(*ChatRooms))
-		}
-	case func(*Session, *ChatMessage):
-		s.Handlers["chat_message"] = func(s *Session, v interface{}) {
-			a(s, v.(*ChatMessage))
-		}
-	case func(*Session, *ChatStats):
-		s.Handlers["chat_stats"] = func(s *Session, v interface{}) {
-			a(s, v.(*ChatStats))
-		}
-	case func(*Session, *CoinflipDeleteGame):
-		s.Handlers["coinflip_delete_game"] = func(s *Session, v interface{}) {
-			a(s, v.(*CoinflipDeleteGame))
-		}
-	case func(*Session, *CoinflipGameStatus):
-		s.Handlers["coinflip_game_status"] = func(s *Session, v interface{}) {
-			a(s, v.(*CoinflipGameStatus))
-		}
-	case func(*Session, *CoinflipList):
-		s.Handlers["coinflip_list"] = func(s *Session, v interface{}) {
-			a(s, v.(*CoinflipList))
-		}
-	case func(*Session, *CoinflipNewGame):
-		s.Handlers["coinflip_new_game"] = func(s *Session, v interface{}) {
-			a(s, v.(*CoinflipNewGame))
-		}
-	case func(*Session, *CoinflipUpdateGame):
-		s.Handlers["coinflip_update_game"] = func(s *Session, v interface{}) {
-			a(s, v.(*CoinflipUpdateGame))
-		}
-	case func(*Session, *CrashCashOut):
-		s.Handlers["crash_cashout"] = func(s *Session, v interface{}) {
-			a(s, v.(*CrashCashOut))
-		}
-	case func(*Session, *CrashMultipleBets):
-		s.Handlers["crash_multiple_bets"] = func(s *Session, v interface{}) {
-			a(s, v.(*CrashMultipleBets))
-		}
-	case func(*Session, *CrashNew):
-		s.Handlers["crash_new"] = func(s *Session, v interface{}) {
-			a(s, v.(*CrashNew))
-		}
-	case func(*Session, *CrashStart):
-		s.Handlers["crash_start"] = func(s *Session, v interface{}) {
-			a(s, v.(*CrashStart))
-		}
-	case func(*Session, *CrashTick):
-		s.Handlers["crash_tick"] = func(s *Session, v interface{}) {
-			a(s, v.(*CrashTick))
-		}
-	case func(*Session, *JackpotList):
-		s.Handlers["jackpot_list"] = func(s *Session, v interface{}) {
-			a(s, v.(*JackpotList))
-		}
-	case func(*Session, *JackpotNewDeposit):
-		s.Handlers["jackpot_new_deposit"] = func(s *Session, v interface{}) {
-			a(s, v.(*JackpotNewDeposit))
-		}
-	case func(*Session, *JackpotNewGame):
-		s.Handlers["jackpot_new_game"] = func(s *Session, v interface{}) {
-			a(s, v.(*JackpotNewGame))
-		}
-	case func(*Session, *JackpotStartTimer):
-		s.Handlers["jackpot_start_timer"] = func(s *Session, v interface{}) {
-			a(s, v.(*JackpotStartTimer))
-		}
-	case func(*Session, *LowJackpotList):
-		s.Handlers["jackpot-low_list"] = func(s *Session, v interface{}) {
-			a(s, v.(*LowJackpotList))
-		}
-	case func(*Session, *LowJackpotNewDeposit):
-		s.Handlers["jackpot-low_new_deposit"] = func(s *Session, v interface{}) {
-			a(s, v.(*LowJackpotNewDeposit))
-		}
-	case func(*Session, *LowJackpotNewGame):
-		s.Handlers["jackpot-low_new_game"] = func(s *Session, v interface{}) {
-			a(s, v.(*LowJackpotNewGame))
-		}
-	case func(*Session, *LowJackpotStartTimer):
-		s.Handlers["jackpot-low_start_timer"] = func(s *Session, v interface{}) {
-			a(s, v.(*LowJackpotStartTimer))
-		}
-	case func(*Session, *MinesBeginTimer):
-		s.Handlers["mines_begin_timer"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesBeginTimer))
-		}
-	case func(*Session, *MinesGameStarted):
-		s.Handlers["mines_game_started"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesGameStarted))
-		}
-	case func(*Session, *MinesGameStarting):
-		s.Handlers["mines_game_starting"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesGameStarting))
-		}
-	case func(*Session, *MinesList):
-		s.Handlers["mines_list"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesList))
-		}
-	case func(*Session, *MinesNewGame):
-		s.Handlers["mines_new_game"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesNewGame))
-		}
-	case func(*Session, *MinesNewPlayer):
-		s.Handlers["mines_new_player"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesNewPlayer))
+// Close closes the websocket, stops any reconnect attempts and waits for the background goroutines to exit
+// Closing a session that isn't open does nothing, handlers are among the goroutines Close waits for so a handler has to use Shutdown instead
+func (s *Session) Close() error {
+	if s.shutdown() {
+		s.wg.Wait()
+	}
+	return nil
+}
+
+// Shutdown closes the websocket and stops any reconnect attempts like Close but returns without waiting for the background goroutines
+// It's how a handler closes its own session, the read loop finishes once the handler returns
+func (s *Session) Shutdown() error {
+	s.shutdown()
+	return nil
+}
+
+// shutdown cancels the running Open call and reports whether there was one
+func (s *Session) shutdown() bool {
+	s.runMu.Lock()
+	r := s.running
+	s.running = nil
+	s.runMu.Unlock()
+	if r == nil {
+		return false
+	}
+	r.cancel()
+	return true
+}
+
+// run is one Open call, Close and the reader use it to tell whether the session is still running the same connection
+type run struct {
+	cancel context.CancelFunc
+	// out is the write queue, it outlives the connections of the run so WriteHold can keep payloads across a reconnect
+	out chan *outgoing
+	// done is closed when the run stops
+	done <-chan struct{}
+}
+
+// connect dials the socket and writes the join_rooms payload followed by the chat room, it's used by Open and every reconnect
+func (s *Session) connect(ctx context.Context) (*websocket.Conn, error) {
+	d := s.socketDialer()
+	stop := cancelHandshake(ctx, d)
+	c, _, err := d.DialContext(ctx, s.socketURL(), s.Headers)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	s.setSocket(c)
+	deadline, _ := ctx.Deadline()
+	if s.WriteTimeout > 0 && (deadline.IsZero() || time.Now().Add(s.WriteTimeout).Before(deadline)) {
+		deadline = time.Now().Add(s.WriteTimeout)
+	}
+	b, err := marshalPayload(&Payload{
+		Data: s.JoinedRooms(),
+		Room: "control",
+		Type: "join_rooms",
+	})
+	if err == nil {
+		err = s.writeFrame(c, b, deadline)
+	}
+	if err == nil && s.Room != "" {
+		b, err = marshalPayload(&Payload{
+			Data: s.Room,
+			Room: "chat",
+			Type: "switch_room",
+		})
+		if err == nil {
+			err = s.writeFrame(c, b, deadline)
 		}
-	case func(*Session, *MinesWinner):
-		s.Handlers["mines_winner"] = func(s *Session, v interface{}) {
-			a(s, v.(*MinesWinner))
+	}
+	if err != nil {
+		s.clearSocket(c)
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// setSocket swaps Session.Socket while holding the socket mutex so it doesn't race with Write
+func (s *Session) setSocket(c *websocket.Conn) {
+	s.SocketMutex.Lock()
+	s.Socket = c
+	s.SocketMutex.Unlock()
+}
+
+// clearSocket sets Session.Socket to nil if it's still c, a handler can open a new connection before the read loop of c is done with it
+func (s *Session) clearSocket(c *websocket.Conn) {
+	s.SocketMutex.Lock()
+	if s.Socket == c {
+		s.Socket = nil
+	}
+	s.SocketMutex.Unlock()
+}
+
+// run reads from c until it fails, then reconnects with a backoff, it only returns once ctx is done
+func (s *Session) run(ctx context.Context, r *run, c *websocket.Conn) {
+	defer s.wg.Done()
+	defer s.stopPool()
+	defer func() {
+		s.runMu.Lock()
+		if s.running == r {
+			s.running = nil
+		}
+		s.runMu.Unlock()
+		r.cancel()
+		failQueued(r.out, ErrNotConnected)
+	}()
+	s.logger().Info("socket connected", "url", s.socketURL(), "rooms", strings.Join(s.JoinedRooms(), ","))
+	// read emits the event once the writer runs so a handler can write from it
+	ready := func() { s.emit("socket_connected", &Connected{}) }
+	for {
+		err := s.read(ctx, c, r.out, ready)
+		s.clearSocket(c)
+		c.Close()
+		if s.ReconnectWrites == WriteDrop {
+			failQueued(r.out, ErrNotConnected)
+		}
+		if ctx.Err() != nil {
+			s.logger().Info("socket closed")
+			s.emit("socket_disconnected", &Disconnected{Err: ctx.Err()})
+			return
+		}
+		s.logger().Warn("socket disconnected", "error", err)
+		s.emit("socket_disconnected", &Disconnected{Err: err})
+		down := time.Now()
+		var attempts int
+		c, attempts = s.reconnect(ctx)
+		if c == nil {
+			return
+		}
+		s.logger().Info("socket resumed", "attempt", attempts, "downtime", time.Since(down))
+		resumed := &Resumed{
+			Attempts: attempts,
+			Downtime: time.Since(down),
+		}
+		ready = func() { s.emit("socket_resumed", resumed) }
+	}
+}
+
+// reconnect keeps trying to connect until it works or ctx is done, in which case it returns nil
+// The number of attempts it took is returned with the connection
+func (s *Session) reconnect(ctx context.Context) (*websocket.Conn, int) {
+	for attempt := 1; ; attempt++ {
+		delay := s.Reconnect.Delay(attempt)
+		s.logger().Info("socket reconnecting", "attempt", attempt, "delay", delay)
+		s.emit("socket_reconnecting", &Reconnecting{
+			Attempt: attempt,
+			Delay:   delay,
+		})
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, attempt
+		case <-t.C:
+		}
+		c, err := s.connect(ctx)
+		if err == nil {
+			return c, attempt
+		}
+		s.logger().Warn("socket reconnect failed", "attempt", attempt, "error", err)
+	}
+}
+
+// emit calls the handlers registered for key with v, it's used for events that don't come from the socket like Connected
+func (s *Session) emit(key string, v interface{}) {
+	s.dispatch(key, s.handlersFor(key), v)
+}
+
+// dispatch calls hs with v and then the handlers added by Events with v wrapped in an *Event, how they're called depends on Session.Dispatch
+func (s *Session) dispatch(key string, hs []*eventHandler, v interface{}) {
+	all := s.handlersFor(anyEvent)
+	if len(hs) == 0 && len(all) == 0 {
+		return
+	}
+	s.deliver(key, func() {
+		callHandlers(s, hs, v)
+		if len(all) > 0 {
+			callHandlers(s, all, &Event{Name: key, Data: v})
 		}
-	case func(*Session, *SupplyDropsJoinable):
-		s.Handlers["supply-drops_joinable"] = func(s *Session, v interface{}) {
-			a(s, v.(*SupplyDropsJoinable))
+	})
+}
+
+// read reads messages from c and hands them to onMessage until reading fails, the connection is closed when ctx is done so the read unblocks
+// Payloads queued on out are written to c by a writer that runs as long as the read loop does, ready is called once it's running
+// Every frame and pong pushes the read deadline back by ReadTimeout, if the keepalive dropped the connection because a room went stale that's the error returned
+// It doesn't return before the keepalive and the writer have stopped so nothing touches c or Session.Recorder after the run is over
+func (s *Session) read(ctx context.Context, c *websocket.Conn, out chan *outgoing, ready func()) error {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	defer func() {
+		close(stop)
+		// closing c unblocks a write that's stuck on the wire
+		c.Close()
+		wg.Wait()
+	}()
+	reason := make(chan error, 1)
+	s.resetSeen()
+	if s.ReadTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		})
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.keepalive(ctx, c, stop, reason)
+	}()
+	go func() {
+		defer wg.Done()
+		s.writeLoop(c, out, stop)
+	}()
+	ready()
+	for {
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			select {
+			case r := <-reason:
+				return r
+			default:
+			}
+			return err
 		}
-	case func(*Session, *SupplyDropsList):
-		s.Handlers["supply-drops_list"] = func(s *Session, v interface{}) {
-			a(s, v.(*SupplyDropsList))
+		if s.ReadTimeout > 0 {
+			c.SetReadDeadline(time.Now().Add(s.ReadTimeout))
 		}
-	case func(*Session, *SupplyDropsPlayers):
-		s.Handlers["supply-drops_players"] = func(s *Session, v interface{}) {
-			a(s, v.(*SupplyDropsPlayers))
+		s.record(Inbound, message)
+		s.onMessage(message)
+	}
+}
+
+// onMessage decodes every payload in a websocket message and calls the handlers registered for it
+func (s *Session) onMessage(message []byte) {
+	for _, msg := range strings.Split(string(message), "\n") {
+		if strings.TrimSpace(msg) == "" {
+			continue
+		}
+		raw := json.RawMessage(msg)
+		var m Payload
+		if err := json.Unmarshal(raw, &m); err != nil {
+			s.decodeError("", raw, err)
+			continue
+		}
+		t := m.Room + "_" + m.Type
+		s.touch(m.Room)
+		s.logger().Debug("socket payload", "room", m.Room, "type", m.Type, "size", len(raw))
+		if rs := s.handlersFor("socket_raw"); len(rs) > 0 {
+			e := &RawEvent{Payload: m, Raw: raw}
+			s.deliver(t, func() {
+				callHandlers(s, rs, e)
+			})
+		}
+		et, ok := lookupEvent(t)
+		if !ok || et.Decode == nil {
+			if s.Drift != nil {
+				s.Drift.Check(t, raw, nil)
+			}
+			s.emit("socket_unknown", &UnknownEvent{Payload: m, Raw: raw})
+			continue
 		}
-	case func(*Session, *SupplyDropWinner):
-		s.Handlers["supply-drops_result"] = func(s *Session, v interface{}) {
-			a(s, v.(*SupplyDropWinner))
+		hs := s.handlersFor(t)
+		if len(hs) == 0 && len(s.handlersFor(anyEvent)) == 0 && s.Drift == nil {
+			continue
 		}
-	case func(*Session, *RouletteRoll):
-		s.Handlers["roulette_roll"] = func(s *Session, v interface{}) {
-			a(s, v.(*RouletteRoll))
+		p, err := et.Decode(raw)
+		if s.Drift != nil {
+			s.Drift.Check(t, raw, p)
 		}
-	case func(*Session, *RouletteList):
-		s.Handlers["roulette_list"] = func(s *Session, v interface{}) {
-			a(s, v.(*RouletteList))
+		if err != nil {
+			s.decodeError(t, raw, err)
+			if s.Strict {
+				continue
+			}
 		}
-	case func(*Session, *UserSetPoints):
-		s.Handlers["user_set_points"] = func(s *Session, v interface{}) {
-			a(s, v.(*UserSetPoints))
+		s.dispatch(t, hs, p)
+	}
+}
+
+// decodeError logs a payload that failed to decode and passes it to Session.OnDecodeError, key is blank when the payload wasn't even valid json
+func (s *Session) decodeError(key string, raw []byte, err error) {
+	s.logger().Error("decoding socket payload failed", "event", key, "size", len(raw), "error", err)
+	if s.OnDecodeError != nil {
+		s.OnDecodeError(s, key, raw, err)
+	}
+}
+
+// AddHandler sets something to do when an event happens, the input is a func that always has a first argument of a *Session and a second argument of another struct
+// The struct decides which event the handler is for, see RegisterEvent for adding events this package doesn't know about
+// Adding a second handler for the same event keeps the first one, both are called in the order they were added
+// The returned func removes the handler again, it's safe to add and remove handlers while the socket is open
+func (s *Session) AddHandler(v interface{}) func() {
+	key, fn := adaptHandler(v)
+	if fn == nil {
+		s.logger().Warn("unknown handler type, this handler will not be called", "handler", fmt.Sprintf("%T", v))
+		return func() {}
+	}
+	return s.addHandler(key, fn)
+}
+
+// eventHandler is a single handler added by AddHandler, it's a pointer so it can be found again when it's removed
+type eventHandler struct {
+	fn func(*Session, interface{})
+}
+
+// addHandler appends fn to the handlers of key and returns a func that removes it
+func (s *Session) addHandler(key string, fn func(*Session, interface{})) func() {
+	h := &eventHandler{fn: fn}
+	s.handlersMu.Lock()
+	if s.handlers == nil {
+		s.handlers = make(map[string][]*eventHandler)
+	}
+	s.handlers[key] = append(s.handlers[key], h)
+	s.handlersMu.Unlock()
+	return func() {
+		s.removeHandler(key, h)
+	}
+}
+
+// removeHandler removes h from the handlers of key, removing it twice does nothing
+func (s *Session) removeHandler(key string, h *eventHandler) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	hs := s.handlers[key]
+	for i, v := range hs {
+		if v == h {
+			// copy so a snapshot taken by handlersFor isn't changed under it
+			n := make([]*eventHandler, 0, len(hs)-1)
+			n = append(n, hs[:i]...)
+			n = append(n, hs[i+1:]...)
+			if len(n) == 0 {
+				delete(s.handlers, key)
+			} else {
+				s.handlers[key] = n
+			}
+			return
 		}
-	default:
-		fmt.Println("Unknown handler type, this handler will not be called")
 	}
 }
 
+// handlersFor returns the handlers of key, the slice is never modified after it's returned so it can be used without holding the lock
+func (s *Session) handlersFor(key string) []*eventHandler {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.handlers[key]
+}
+
 // SwitchRoom takes in a room type, then a type, then a data
 // Room can be for example "chat"
 // Type can be for example "switch_room"
 // Data can be anything, it's a type interface{}
 func (s *Session) SwitchRoom(room, t string, data interface{}) error {
-	err := s.Write(&Payload{
+	return s.SwitchRoomCtx(context.Background(), room, t, data)
+}
+
+// SwitchRoomCtx is SwitchRoom with a context
+func (s *Session) SwitchRoomCtx(ctx context.Context, room, t string, data interface{}) error {
+	err := s.WriteCtx(ctx, &Payload{
 		Room: room,
 		Type: t,
 		Data: data,
@@ -550,10 +560,15 @@ func (s *Session) SwitchRoom(room, t string, data interface{}) error {
 
 // SwitchChatRoom takes in a room type of "en", "tr", or "ru"
 func (s *Session) SwitchChatRoom(room string) error {
+	return s.SwitchChatRoomCtx(context.Background(), room)
+}
+
+// SwitchChatRoomCtx is SwitchChatRoom with a context
+func (s *Session) SwitchChatRoomCtx(ctx context.Context, room string) error {
 	if room != "en" && room != "tr" && room != "ru" {
 		return fmt.Errorf("invalid room input")
 	}
-	err := s.Write(&Payload{
+	err := s.WriteCtx(ctx, &Payload{
 		Room: "chat",
 		Type: "switch_room",
 		Data: room,
@@ -566,10 +581,15 @@ func (s *Session) SwitchChatRoom(room string) error {
 // GameID is the game id, get this from the RouletteRoll event in the "NewGame" field of "Data"
 // Color int can be 0 for blue, 1 for yellow, and 2 for red
 func (s *Session) BetRoulette(amount, gameID, color int) error {
+	return s.BetRouletteCtx(context.Background(), amount, gameID, color)
+}
+
+// BetRouletteCtx is BetRoulette with a context
+func (s *Session) BetRouletteCtx(ctx context.Context, amount, gameID, color int) error {
 	if color < 0 || color > 2 {
 		return fmt.Errorf("color out of range 0-2")
 	}
-	err := s.Write(&Payload{
+	err := s.WriteCtx(ctx, &Payload{
 		Room: "roulette",
 		Type: "join_game",
 		Data: &EnterRouletteData{
@@ -583,7 +603,12 @@ func (s *Session) BetRoulette(amount, gameID, color int) error {
 
 // JoinSupplyDrop joins a supply drop
 func (s *Session) JoinSupplyDrop() error {
-	return s.Write(&Payload{
+	return s.JoinSupplyDropCtx(context.Background())
+}
+
+// JoinSupplyDropCtx is JoinSupplyDrop with a context
+func (s *Session) JoinSupplyDropCtx(ctx context.Context) error {
+	return s.WriteCtx(ctx, &Payload{
 		Room: "supply-drops",
 		Type: "join",
 		Data: nil,
@@ -0,0 +1,282 @@
+package wrapper_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+// fastReconnect keeps reconnect delays short so tests don't wait on the default backoff
+var fastReconnect = wrapper.Backoff{Min: 10 * time.Millisecond, Max: 50 * time.Millisecond, Factor: 2}
+
+// newSession returns a session pointed at srv that's closed when the test ends
+func newSession(t *testing.T, srv *mock.Server, opts ...wrapper.Option) *wrapper.Session {
+	t.Helper()
+	s, err := wrapper.NewSession(append([]wrapper.Option{wrapper.WithEndpoints(srv.Endpoints())}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	s.Reconnect = fastReconnect
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// waitJoin waits for n join_rooms payloads on srv
+func waitJoin(t *testing.T, srv *mock.Server, n int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.WaitForJoin(ctx, n); err != nil {
+		t.Fatalf("waiting for %d joins: %v", n, err)
+	}
+}
+
+// waitFor fails the test when ch doesn't receive within 5 seconds
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// notify signals ch without blocking so a handler never holds up the read loop that Close waits for
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// eventually fails the test when cond isn't true within 5 seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOpenClose(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("crash", "roulette"))
+	disconnected := make(chan error, 1)
+	s.AddHandler(func(_ *wrapper.Session, d *wrapper.Disconnected) {
+		disconnected <- d.Err
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := s.Open(); err != wrapper.ErrAlreadyOpen {
+		t.Fatalf("second Open = %v, want ErrAlreadyOpen", err)
+	}
+	waitJoin(t, srv, 1)
+	rooms := srv.Clients()[0].Rooms()
+	if len(rooms) != 2 {
+		t.Fatalf("client joined %v, want crash and roulette", rooms)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-disconnected:
+		if err != context.Canceled {
+			t.Fatalf("Disconnected.Err = %v, want context.Canceled", err)
+		}
+	default:
+		t.Fatal("Close returned before Disconnected was sent")
+	}
+	if err := s.Write(&wrapper.Payload{Room: "chat", Type: "send_message"}); err != wrapper.ErrNotConnected {
+		t.Fatalf("Write after Close = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv)
+	resumed := make(chan struct{}, 1)
+	s.AddHandler(func(_ *wrapper.Session, r *wrapper.Resumed) {
+		notify(resumed)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	srv.DropClients()
+	waitFor(t, resumed, "the socket to resume")
+	waitJoin(t, srv, 1)
+	if n := len(srv.Clients()); n != 1 {
+		t.Fatalf("%d clients connected after the reconnect, want 1", n)
+	}
+}
+
+func TestShutdownFromHandler(t *testing.T) {
+	modes := map[string]wrapper.DispatchMode{
+		"sync":      wrapper.DispatchSync,
+		"goroutine": wrapper.DispatchGoroutine,
+		"pool":      wrapper.DispatchPool,
+	}
+	for name, mode := range modes {
+		mode := mode
+		t.Run(name, func(t *testing.T) {
+			srv := mock.NewServer()
+			defer srv.Close()
+			s := newSession(t, srv, wrapper.WithRooms("shop"))
+			s.Dispatch = mode
+			closed := make(chan struct{})
+			s.AddHandler(func(s *wrapper.Session, _ *wrapper.ShopRules) {
+				s.Shutdown()
+				close(closed)
+			})
+			if err := s.Open(); err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			waitJoin(t, srv, 1)
+			srv.Emit("shop", "rules", map[string]interface{}{})
+			waitFor(t, closed, "Shutdown to return inside the handler")
+			if err := s.Open(); err != nil {
+				t.Fatalf("Open after Shutdown: %v", err)
+			}
+		})
+	}
+}
+
+func TestCloseFromOtherSessionsHandler(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	a := newSession(t, srv, wrapper.WithRooms("shop"))
+	b := newSession(t, srv, wrapper.WithRooms("crash"))
+	b.Dispatch = wrapper.DispatchGoroutine
+	var finished int32
+	started := make(chan struct{}, 1)
+	b.AddHandler(func(_ *wrapper.Session, _ *wrapper.CrashTick) {
+		notify(started)
+		time.Sleep(200 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+	})
+	closed := make(chan int32, 1)
+	a.AddHandler(func(_ *wrapper.Session, _ *wrapper.ShopRules) {
+		b.Close()
+		closed <- atomic.LoadInt32(&finished)
+	})
+	if err := a.Open(); err != nil {
+		t.Fatalf("Open a: %v", err)
+	}
+	if err := b.Open(); err != nil {
+		t.Fatalf("Open b: %v", err)
+	}
+	waitJoin(t, srv, 2)
+	srv.Emit("crash", "tick", 100)
+	waitFor(t, started, "b's handler to start")
+	srv.Emit("shop", "rules", map[string]interface{}{})
+	select {
+	case f := <-closed:
+		if f != 1 {
+			t.Fatal("b.Close returned while b's handler was still running")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("b.Close never returned")
+	}
+}
+
+func TestReopenFromHandler(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("shop"))
+	reopened := make(chan error, 1)
+	s.AddHandler(func(s *wrapper.Session, _ *wrapper.ShopRules) {
+		s.Shutdown()
+		reopened <- s.Open()
+	})
+	stopped := make(chan struct{}, 1)
+	s.AddHandler(func(_ *wrapper.Session, d *wrapper.Disconnected) {
+		if d.Err == context.Canceled {
+			notify(stopped)
+		}
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	srv.Emit("shop", "rules", map[string]interface{}{})
+	select {
+	case err := <-reopened:
+		if err != nil {
+			t.Fatalf("Open from the handler: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Open from the handler never returned")
+	}
+	// the first run is done once it reports it was cancelled, it mustn't take the new connection with it
+	waitFor(t, stopped, "the first run to stop")
+	if err := s.Write(&wrapper.Payload{Room: "chat", Type: "send_message"}); err != nil {
+		t.Fatalf("Write after reopening: %v", err)
+	}
+}
+
+func TestCloseAbortsOpen(t *testing.T) {
+	// a listener that accepts connections and never answers the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			notify(accepted)
+		}
+	}()
+	s, err := wrapper.NewSession(wrapper.WithEndpoints(wrapper.Endpoints{Socket: "ws://" + l.Addr().String() + "/feed"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := make(chan error, 1)
+	go func() {
+		opened <- s.Open()
+	}()
+	waitFor(t, accepted, "Open to dial")
+	// writes don't wait on the dial
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Write(&wrapper.Payload{Room: "chat", Type: "send_message"})
+	}()
+	select {
+	case err := <-done:
+		if err != wrapper.ErrNotConnected {
+			t.Fatalf("Write while dialing = %v, want ErrNotConnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write waited on the dial")
+	}
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+	waitFor(t, closed, "Close to abort the dial")
+	select {
+	case err := <-opened:
+		if err == nil {
+			t.Fatal("Open worked against a server that never answers")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Open didn't return after Close")
+	}
+}
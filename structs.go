@@ -1,6 +1,9 @@
 package wrapper
 
 import (
+	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -14,18 +17,131 @@ type Session struct {
 	Auth string
 	// Socket is the main socket, if you want to interact with the Socket directly this is what you would use
 	Socket *websocket.Conn
-	// SocketMutex is the socket mutex to stop concurrent writing
+	// SocketMutex is held while a frame is written to Socket and while Socket is swapped, hold it if you write to Socket directly
 	SocketMutex sync.Mutex
-	// Handlers is a map of handlers where string is the room_type and the func is added by the AddHandler func
-	Handlers map[string]func(*Session, interface{})
-	// Headers is used when connecting to the socket (maybe http requests) and are set automatically, incase you want to set them yourself though the option is always there
+	// Headers is used when connecting to the socket and are set automatically, the User-Agent is also sent with http requests, use WithHeaders or WithUserAgent to change them
 	Headers http.Header
-	// Rooms is what rooms we want to listen for on the socket, by default it's []string{"chat", "crash", "shop", "coinflip", "jackpot", "jackpot-low", "supply-drops", "mines"}
+	// Rooms is what rooms we want to listen for on the socket, by default it's every room in KnownRooms, use JoinRooms and LeaveRooms to change it while the socket is open
 	Rooms []string
 	// Room is the chat room we join, default is "en" but it can also be "tr" or "ru"
 	Room string
-	// Log is logging errors to console, this is defaulted as false
+	// Log is logging errors and warnings to console through the standard log package when Logger isn't set, this is defaulted as false
 	Log bool
+	// Logger receives the session's logs as key value records, a *slog.Logger can be used directly, when it's set Log is ignored
+	Logger Logger
+	// Dialer opens the socket, the proxy, TLS config and dial function it leaves nil are taken from the transport of HTTPClient so both go the same way, set Proxy to return nil to skip the proxy
+	Dialer *websocket.Dialer
+	// DialContext opens the tcp connections of the socket when it's set, it wins over the dial function of Dialer, see WithDialFunc
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	// HTTPClient is the client every http request goes through, New sets one up with DefaultHTTPTimeout, if it's nil http.DefaultClient is used
+	HTTPClient *http.Client
+	// Endpoints is where the session sends http requests and where it opens the socket, by default this is rustchance.com
+	Endpoints Endpoints
+	// Reconnect is the backoff between reconnect attempts after the socket drops, the zero value uses DefaultBackoff
+	Reconnect Backoff
+	// OnDecodeError is called with the event key, the raw payload and the error when a socket payload can't be decoded, the key is blank when the payload isn't valid json at all
+	OnDecodeError func(s *Session, key string, raw []byte, err error)
+	// Strict skips the handlers of a payload that failed to decode, by default they're still called with whatever part of the struct did decode
+	Strict bool
+	// Drift checks every socket payload and http response against its struct when it's set, see NewDriftDetector, it's off by default
+	Drift *DriftDetector
+	// Recorder gets every frame read from and written to the socket when it's set, see FileRecorder, it's off by default
+	Recorder Recorder
+	// Dispatch is how handlers are called, by default they're called one after another on the read loop, see DispatchMode
+	Dispatch DispatchMode
+	// Workers is the number of pool workers when Dispatch is DispatchPool, zero means runtime.NumCPU()
+	Workers int
+	// WorkerQueue is how many events each pool worker can have waiting, zero means DefaultWorkerQueue
+	WorkerQueue int
+	// RateLimits is the token bucket of each endpoint family like FamilyHistory, families without one aren't limited, set it before the first request or use WithRateLimit
+	RateLimits map[string]RateLimit
+	// Retry is how failed GET requests are retried, New sets DefaultRetryPolicy and the zero value never retries
+	Retry RetryPolicy
+	// PingInterval is how often the socket is pinged to keep it alive, New sets DefaultPingInterval and zero sends no pings
+	PingInterval time.Duration
+	// ReadTimeout is how long the socket can go without a frame or a pong before it's reconnected, New sets DefaultReadTimeout and zero waits forever
+	ReadTimeout time.Duration
+	// StaleTimeout is how long each joined room can go without a payload before the socket is reconnected, rooms without an entry are never stale, set it before Open or use WithStaleTimeout
+	StaleTimeout map[string]time.Duration
+	// WriteQueue is how many payloads can wait to be written, zero means DefaultWriteQueue
+	WriteQueue int
+	// WriteTimeout is how long a write can take counting the time it waits in the queue, New sets DefaultWriteTimeout and zero leaves it to the context of the write
+	WriteTimeout time.Duration
+	// ReconnectWrites is what happens to writes while the socket is reconnecting, by default they fail with ErrNotConnected, see WritePolicy
+	ReconnectWrites WritePolicy
+
+	// roomsMu guards Rooms once the session is in use
+	roomsMu sync.Mutex
+	// seenMu guards seen
+	seenMu sync.Mutex
+	// seen is when each room last sent a payload on the current connection, it's only kept when StaleTimeout is set
+	seen map[string]time.Time
+	// runMu guards running
+	runMu sync.Mutex
+	// running is the current Open call, it's nil while the socket is closed
+	running *run
+	// handlersMu guards handlers
+	handlersMu sync.RWMutex
+	// handlers is a map of handlers where string is the room_type and the funcs are added by the AddHandler func
+	handlers map[string][]*eventHandler
+	// poolMu guards pool
+	poolMu sync.RWMutex
+	// pool is the worker pool used by DispatchPool, it's started by the first event and stopped when the socket closes
+	pool *workerPool
+	// wg tracks the background goroutines started by Open so Close can wait for them
+	wg sync.WaitGroup
+	// bucketsMu guards buckets
+	bucketsMu sync.Mutex
+	// buckets is the rate limiter state of every endpoint family that has been requested
+	buckets map[string]*bucket
+}
+
+// Endpoints is the set of origins a Session talks to, change these to point a session at a mirror or a local test server
+type Endpoints struct {
+	// HTTP is the origin every http request is made against, for example "https://rustchance.com" or an httptest server URL
+	HTTP string
+	// Socket is the websocket url that Open dials, for example "wss://rustchance.com/feed"
+	Socket string
+}
+
+// Connected is sent to handlers once the socket is connected after Open, the join_rooms payload has already been written at this point
+type Connected struct{}
+
+// Disconnected is sent to handlers when the socket drops, Err is why it dropped, it's context.Canceled after Close
+type Disconnected struct {
+	Err error
+}
+
+// Reconnecting is sent to handlers before every reconnect attempt
+type Reconnecting struct {
+	// Attempt is the number of this attempt, it starts at 1 after every drop
+	Attempt int
+	// Delay is how long the session waits before dialing
+	Delay time.Duration
+}
+
+// Resumed is sent to handlers when a reconnect worked and the rooms have been joined again
+type Resumed struct {
+	// Attempts is how many attempts it took to reconnect
+	Attempts int
+	// Downtime is how long the socket was down
+	Downtime time.Duration
+}
+
+// RawEvent is sent to handlers for every payload the socket receives, before it's decoded into its event struct
+type RawEvent struct {
+	// Payload is the payload with its data decoded generically, Room and Type make up the event key like "crash_tick"
+	Payload Payload
+	// Raw is the payload exactly as it was received
+	Raw json.RawMessage
+}
+
+// UnknownEvent is sent to handlers for payloads whose room_type this package doesn't know about yet
+type UnknownEvent struct {
+	// Payload is the payload with its data decoded generically
+	Payload Payload
+	// Raw is the payload exactly as it was received
+	Raw json.RawMessage
 }
 
 // Payload is the typical payload, this should be able to be used 99% of the time when writing to the socket
@@ -803,14 +919,14 @@ type AccountInfo struct {
 
 // FaucetResponse is the response from attempting to claim the faucet
 type FaucetResponse struct {
-	Err         string `json:"error"`
+	Err         string `json:"error,omitempty"`
 	AmountAdded int    `json:"result"`
 	Success     bool   `json:"success"`
 }
 
 // ProvablyFair is the response from ProvefairSerialURL
 type ProvablyFair struct {
-	Err    string `json:"error"`
+	Err    string `json:"error,omitempty"`
 	Result struct {
 		CreatedAt    string `json:"createdAt"`
 		Game         string `json:"game"`
@@ -827,7 +943,7 @@ type ProvablyFair struct {
 
 // RedeemCodeResponse is the response from attempting to redeem a sponsor code
 type RedeemCodeResponse struct {
-	Err     string `json:"error"`
+	Err     string `json:"error,omitempty"`
 	Message string `json:"message"`
 	Success bool   `json:"success"`
 }
@@ -0,0 +1,138 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// anyEvent is the handler key Events registers under, handlers for it get every event wrapped in an *Event
+const anyEvent = "*"
+
+// DefaultSubscribeBuffer is the channel buffer Subscribe and Events use when SubscribeOptions.Buffer isn't set
+const DefaultSubscribeBuffer = 64
+
+// OverflowPolicy decides what a subscription does with an event when its channel is full
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the event that didn't fit, the read loop never waits on a slow consumer
+	DropNewest OverflowPolicy = iota
+	// Block waits until the consumer makes room or the subscription's context is done, a slow consumer holds up the read loop
+	Block
+)
+
+// SubscribeOptions configures the channel returned by Subscribe or Events
+type SubscribeOptions struct {
+	// Buffer is the size of the channel buffer, DefaultSubscribeBuffer is used when it's zero
+	Buffer int
+	// Overflow is what happens when the buffer is full, the default is DropNewest
+	Overflow OverflowPolicy
+}
+
+// Event is what the Events channel sends, it's the sum of every event the session dispatches
+type Event struct {
+	// Name is the event key like "crash_tick" or "socket_disconnected"
+	Name string
+	// Data is the decoded event, for example a *CrashTick or a *Disconnected
+	Data interface{}
+}
+
+// Subscribe returns a channel that receives every event with the given name, for example Subscribe(ctx, "crash_tick") receives *CrashTick values
+// The subscription ends and the channel is closed when ctx is done
+// Opts is optional, only the first one is used
+func (s *Session) Subscribe(ctx context.Context, name string, opts ...SubscribeOptions) (<-chan interface{}, error) {
+	if _, ok := lookupEvent(name); !ok {
+		return nil, errors.New("unknown event " + name)
+	}
+	sub := newSubscription(ctx, opts)
+	ch := make(chan interface{}, sub.buffer)
+	sub.start(s.addHandler(name, func(s *Session, v interface{}) {
+		sub.send(func() bool {
+			select {
+			case ch <- v:
+				return true
+			default:
+				return false
+			}
+		}, func() {
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+			}
+		})
+	}), func() { close(ch) })
+	return ch, nil
+}
+
+// Events returns a channel that receives every event the session dispatches, socket events and lifecycle events like Disconnected alike
+// RawEvent isn't sent since every payload already arrives as its decoded event or an UnknownEvent
+// The channel is closed when ctx is done, opts is optional and only the first one is used
+func (s *Session) Events(ctx context.Context, opts ...SubscribeOptions) <-chan *Event {
+	sub := newSubscription(ctx, opts)
+	ch := make(chan *Event, sub.buffer)
+	sub.start(s.addHandler(anyEvent, func(s *Session, v interface{}) {
+		e := v.(*Event)
+		sub.send(func() bool {
+			select {
+			case ch <- e:
+				return true
+			default:
+				return false
+			}
+		}, func() {
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+			}
+		})
+	}), func() { close(ch) })
+	return ch
+}
+
+// subscription is the shared part of Subscribe and Events, it makes sure nothing is sent on the channel after it's closed
+type subscription struct {
+	ctx      context.Context
+	buffer   int
+	overflow OverflowPolicy
+	// mu is held while sending so the channel can't be closed mid send
+	mu     sync.Mutex
+	closed bool
+}
+
+// newSubscription applies the defaults to opts
+func newSubscription(ctx context.Context, opts []SubscribeOptions) *subscription {
+	sub := &subscription{ctx: ctx, buffer: DefaultSubscribeBuffer}
+	if len(opts) > 0 {
+		if opts[0].Buffer > 0 {
+			sub.buffer = opts[0].Buffer
+		}
+		sub.overflow = opts[0].Overflow
+	}
+	return sub
+}
+
+// start waits for the subscription context in the background, then removes the handler and closes the channel
+func (sub *subscription) start(remove func(), closeChan func()) {
+	go func() {
+		<-sub.ctx.Done()
+		remove()
+		sub.mu.Lock()
+		sub.closed = true
+		closeChan()
+		sub.mu.Unlock()
+	}()
+}
+
+// send delivers an event with trySend, or with block when the channel is full and the policy is Block
+func (sub *subscription) send(trySend func() bool, block func()) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed || sub.ctx.Err() != nil {
+		return
+	}
+	if trySend() || sub.overflow != Block {
+		return
+	}
+	block()
+}
@@ -0,0 +1,211 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultWriteQueue is how many payloads can wait to be written when Session.WriteQueue is zero
+	DefaultWriteQueue = 64
+	// DefaultWriteTimeout is how long NewSession lets a write wait in the queue and on the wire before giving up on it
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrNotConnected is returned by Write when the socket isn't open, or is reconnecting and Session.ReconnectWrites is WriteDrop
+	ErrNotConnected = errors.New("socket is not connected")
+	// ErrWriteQueueFull is returned by Enqueue when the write queue has no room left
+	ErrWriteQueueFull = errors.New("write queue is full")
+	// ErrWriteTimeout is returned by Write when the payload couldn't be written within Session.WriteTimeout
+	ErrWriteTimeout = errors.New("write timed out")
+)
+
+// WritePolicy is what happens to writes while the socket is reconnecting
+type WritePolicy int
+
+const (
+	// WriteDrop fails writes with ErrNotConnected while reconnecting and fails anything still queued when the socket drops, it's the default
+	WriteDrop WritePolicy = iota
+	// WriteHold keeps writes in the queue while reconnecting and sends them once the socket is back, as long as their deadline hasn't passed
+	WriteHold
+)
+
+// WithWriteQueue sets how many payloads can wait to be written and what happens to them while the socket is reconnecting
+func WithWriteQueue(size int, policy WritePolicy) Option {
+	return func(s *Session) error {
+		if size < 0 {
+			return errors.New("write queue size can't be negative")
+		}
+		if policy != WriteDrop && policy != WriteHold {
+			return errors.New("unknown write policy")
+		}
+		s.WriteQueue = size
+		s.ReconnectWrites = policy
+		return nil
+	}
+}
+
+// WithWriteTimeout sets how long a write can take counting the time it waits in the queue, zero leaves it to the context of the write
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Session) error {
+		if d < 0 {
+			return errors.New("write timeout can't be negative")
+		}
+		s.WriteTimeout = d
+		return nil
+	}
+}
+
+// outgoing is one payload waiting in the write queue
+type outgoing struct {
+	b []byte
+	// deadline is when the payload isn't worth sending anymore, zero means never
+	deadline time.Time
+	// done gets the result of the write, it's buffered so the writer never waits on a caller that gave up, it's nil for Enqueue
+	done chan error
+	// conn is the connection the payload was queued for under WriteDrop, a writer for any other connection fails it, it's nil under WriteHold
+	conn *websocket.Conn
+	// state is writeQueued until either the writer claims the payload or the caller gives up on it, whoever is first decides
+	state int32
+}
+
+// States of a queued payload
+const (
+	writeQueued int32 = iota
+	writeClaimed
+	writeAbandoned
+)
+
+// claim marks w as taken by the writer, it fails when the caller already gave up on it so it must not be sent
+func (w *outgoing) claim() bool {
+	return atomic.CompareAndSwapInt32(&w.state, writeQueued, writeClaimed)
+}
+
+// abandon marks w as given up by the caller, it fails when the writer already took it and the caller has to wait for the result
+func (w *outgoing) abandon() bool {
+	return atomic.CompareAndSwapInt32(&w.state, writeQueued, writeAbandoned)
+}
+
+// finish reports the result of the write to whoever is waiting for it
+func (w *outgoing) finish(err error) {
+	if w.done != nil {
+		w.done <- err
+	}
+}
+
+// newOutgoing builds a queued payload for conn whose deadline is the earliest of ctx's deadline and WriteTimeout from now
+func (s *Session) newOutgoing(ctx context.Context, conn *websocket.Conn, b []byte, wait bool) *outgoing {
+	w := &outgoing{b: b, conn: conn}
+	if s.WriteTimeout > 0 {
+		w.deadline = time.Now().Add(s.WriteTimeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (w.deadline.IsZero() || d.Before(w.deadline)) {
+		w.deadline = d
+	}
+	if wait {
+		w.done = make(chan error, 1)
+	}
+	return w
+}
+
+// queueFor returns the running Open call if writes can be queued on it right now
+// Under WriteDrop it also returns the connection the write is for, the socket can drop before the payload is queued so the writer checks it
+func (s *Session) queueFor() (*run, *websocket.Conn, error) {
+	s.runMu.Lock()
+	r := s.running
+	s.runMu.Unlock()
+	if r == nil {
+		return nil, nil, ErrNotConnected
+	}
+	if s.ReconnectWrites == WriteHold {
+		return r, nil, nil
+	}
+	s.SocketMutex.Lock()
+	c := s.Socket
+	s.SocketMutex.Unlock()
+	if c == nil {
+		return nil, nil, ErrNotConnected
+	}
+	return r, c, nil
+}
+
+// Enqueue queues a payload to be written without waiting for it, it returns ErrWriteQueueFull straight away when the queue has no room
+// Failed writes of queued payloads are only logged, use Write when the result matters
+func (s *Session) Enqueue(toWrite interface{}) error {
+	b, err := marshalPayload(toWrite)
+	if err != nil {
+		return err
+	}
+	r, c, err := s.queueFor()
+	if err != nil {
+		return err
+	}
+	select {
+	case r.out <- s.newOutgoing(context.Background(), c, b, false):
+		return nil
+	default:
+		return ErrWriteQueueFull
+	}
+}
+
+// writeFrame writes b to c with the given deadline while holding the socket mutex, it's the only place that writes text frames
+func (s *Session) writeFrame(c *websocket.Conn, b []byte, deadline time.Time) error {
+	s.SocketMutex.Lock()
+	defer s.SocketMutex.Unlock()
+	c.SetWriteDeadline(deadline)
+	err := c.WriteMessage(websocket.TextMessage, b)
+	if err == nil {
+		s.record(Outbound, b)
+	}
+	return err
+}
+
+// writeLoop writes queued payloads to c until stop is closed, a failed write closes c so the read loop reconnects
+func (s *Session) writeLoop(c *websocket.Conn, out chan *outgoing, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case w := <-out:
+			if !w.claim() {
+				continue
+			}
+			if w.conn != nil && w.conn != c {
+				w.finish(ErrNotConnected)
+				continue
+			}
+			if !w.deadline.IsZero() && time.Now().After(w.deadline) {
+				w.finish(ErrWriteTimeout)
+				continue
+			}
+			err := s.writeFrame(c, w.b, w.deadline)
+			w.finish(err)
+			if err != nil {
+				if w.done == nil {
+					s.logger().Warn("queued write failed", "size", len(w.b), "error", err)
+				}
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// failQueued fails everything waiting in out with err, it's used when the socket drops under WriteDrop and when the session closes
+func failQueued(out chan *outgoing, err error) {
+	for {
+		select {
+		case w := <-out:
+			if w.claim() {
+				w.finish(err)
+			}
+		default:
+			return
+		}
+	}
+}
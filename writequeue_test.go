@@ -0,0 +1,159 @@
+package wrapper_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	wrapper "github.com/post04/rustchance-api-wrapper"
+	"github.com/post04/rustchance-api-wrapper/mock"
+)
+
+func TestWriteFromLifecycleHandlers(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithWriteTimeout(2*time.Second))
+	results := make(chan error, 2)
+	s.AddHandler(func(s *wrapper.Session, _ *wrapper.Connected) {
+		results <- s.SwitchChatRoom("ru")
+	})
+	s.AddHandler(func(s *wrapper.Session, _ *wrapper.Resumed) {
+		results <- s.SwitchChatRoom("tr")
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for i, event := range []string{"Connected", "Resumed"} {
+		select {
+		case err := <-results:
+			if err != nil {
+				t.Fatalf("write from %s handler: %v", event, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("write from %s handler didn't finish", event)
+		}
+		if i == 0 {
+			waitJoin(t, srv, 1)
+			srv.DropClients()
+		}
+	}
+}
+
+// writeWhileDown writes a payload of type t from a Disconnected handler, while the session waits to reconnect, and returns the result of the write
+func writeWhileDown(t *testing.T, policy wrapper.WritePolicy, typ string) (*mock.Server, error) {
+	t.Helper()
+	srv := mock.NewServer()
+	t.Cleanup(srv.Close)
+	s := newSession(t, srv, wrapper.WithWriteQueue(8, policy), wrapper.WithWriteTimeout(5*time.Second))
+	s.Reconnect = wrapper.Backoff{Min: 100 * time.Millisecond, Max: 100 * time.Millisecond, Factor: 1}
+	result := make(chan error, 1)
+	s.AddHandler(func(s *wrapper.Session, d *wrapper.Disconnected) {
+		if d.Err == context.Canceled {
+			return
+		}
+		// the handler runs on the read loop, writing from it has to happen elsewhere so the reconnect isn't held up
+		go func() {
+			result <- s.Write(&wrapper.Payload{Room: "chat", Type: typ})
+		}()
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	srv.DropClients()
+	select {
+	case err := <-result:
+		return srv, err
+	case <-time.After(5 * time.Second):
+		t.Fatal("write while reconnecting never returned")
+	}
+	return nil, nil
+}
+
+// received says whether srv got a payload of type t
+func received(srv *mock.Server, typ string) bool {
+	for _, p := range srv.Received() {
+		if p.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWriteDropWhileReconnecting(t *testing.T) {
+	srv, err := writeWhileDown(t, wrapper.WriteDrop, "dropped")
+	if err != wrapper.ErrNotConnected {
+		t.Fatalf("Write while reconnecting = %v, want ErrNotConnected", err)
+	}
+	waitJoin(t, srv, 1)
+	time.Sleep(50 * time.Millisecond)
+	if received(srv, "dropped") {
+		t.Fatal("a dropped write was sent after the reconnect")
+	}
+}
+
+func TestWriteHoldWhileReconnecting(t *testing.T) {
+	srv, err := writeWhileDown(t, wrapper.WriteHold, "held")
+	if err != nil {
+		t.Fatalf("Write while reconnecting = %v, want it sent after the reconnect", err)
+	}
+	eventually(t, "the held payload to reach the server", func() bool {
+		return received(srv, "held")
+	})
+}
+
+func TestWriteBeforeOpen(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv)
+	if err := s.Write(&wrapper.Payload{Room: "chat", Type: "send_message"}); err != wrapper.ErrNotConnected {
+		t.Fatalf("Write before Open = %v, want ErrNotConnected", err)
+	}
+	if err := s.Enqueue(&wrapper.Payload{Room: "chat", Type: "send_message"}); err != wrapper.ErrNotConnected {
+		t.Fatalf("Enqueue before Open = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestCancelledHeldWriteIsNotSent(t *testing.T) {
+	srv := mock.NewServer()
+	defer srv.Close()
+	s := newSession(t, srv, wrapper.WithRooms("roulette"), wrapper.WithWriteQueue(8, wrapper.WriteHold))
+	s.Reconnect = wrapper.Backoff{Min: 300 * time.Millisecond, Max: 300 * time.Millisecond, Factor: 1}
+	down := make(chan struct{}, 1)
+	s.AddHandler(func(_ *wrapper.Session, d *wrapper.Disconnected) {
+		if d.Err != context.Canceled {
+			notify(down)
+		}
+	})
+	resumed := make(chan struct{}, 1)
+	s.AddHandler(func(_ *wrapper.Session, _ *wrapper.Resumed) {
+		notify(resumed)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitJoin(t, srv, 1)
+	srv.DropClients()
+	waitFor(t, down, "the socket to drop")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := s.BetRouletteCtx(ctx, 100, 1, 0); err != context.Canceled {
+		t.Fatalf("BetRouletteCtx while reconnecting = %v, want context.Canceled", err)
+	}
+	waitFor(t, resumed, "the socket to resume")
+	// a write queued after the cancelled one goes out, so once it's in the cancelled one would have been too
+	if err := s.SwitchChatRoom("ru"); err != nil {
+		t.Fatalf("SwitchChatRoom: %v", err)
+	}
+	eventually(t, "the chat room switch to reach the server", func() bool {
+		for _, p := range srv.Received() {
+			if p.Type == "switch_room" && p.Data == "ru" {
+				return true
+			}
+		}
+		return false
+	})
+	if received(srv, "join_game") {
+		t.Fatal("the cancelled bet was sent after the reconnect")
+	}
+}